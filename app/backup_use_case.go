@@ -1,14 +1,19 @@
 package app
 
 import (
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
 )
 
 // BackupUseCase orchestrates the backup process
@@ -25,106 +30,317 @@ func NewBackupUseCase(databaseGateway *data.DatabaseGateway, storageGateway *dat
 	}
 }
 
+// BackupOptions configures a backup run
+type BackupOptions struct {
+	Retention        domain.RetentionPolicy
+	Codec            data.Codec
+	CompressionLevel int
+	// Encryption is optional; nil stores backups unencrypted
+	Encryption       data.Encryption
+	EncryptionConfig data.EncryptionConfig
+	// DryRun reports what would be backed up and pruned without writing or deleting anything
+	DryRun bool
+	// Parallel is the number of databases backed up at once; values below 1 mean one
+	Parallel int
+	// Type is domain.BackupTypeLogical (the default) or domain.BackupTypePhysical, which
+	// backs up the whole server as data.PhysicalDatabase
+	Type string
+	// Connection and ToolVersion are recorded in each backup's manifest
+	Connection  string
+	ToolVersion string
+}
+
 // Execute executes the backup process
-func (uc *BackupUseCase) Execute(retentionCount int, backupDir string, s3Bucket string, s3Path string, compress bool) error {
-	databases, err := uc.databaseGateway.ListDatabases()
-	if err != nil {
-		return fmt.Errorf("failed to list databases: %w", err)
+func (uc *BackupUseCase) Execute(opts BackupOptions) error {
+	var databases []*domain.Database
+	var err error
+	if opts.Type == domain.BackupTypePhysical {
+		if err := uc.databaseGateway.CheckPhysical(); err != nil {
+			return err
+		}
+		databases = []*domain.Database{domain.NewDatabase(data.PhysicalDatabase)}
+	} else {
+		databases, err = uc.databaseGateway.ListDatabases()
+		if err != nil {
+			return fmt.Errorf("failed to list databases: %w", err)
+		}
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %d database(s) selected, retention policy: %s\n", len(databases), opts.Retention)
 	}
 	
-	for _, db := range databases {
-		timestamp := time.Now().Format("20060102150405")
-		backupFilename := fmt.Sprintf("%s-%s.sql", db.Name, timestamp)
-		
-		if backupDir != "" {
-			// Local backup
-			dbBackupDir := filepath.Join(backupDir, db.Name)
-			if err := os.MkdirAll(dbBackupDir, 0755); err != nil {
-				return fmt.Errorf("failed to create backup directory: %w", err)
-			}
-			
-			backupFilepath := filepath.Join(dbBackupDir, backupFilename)
-			if err := uc.databaseGateway.BackupDatabase(db.Name, backupFilepath); err != nil {
-				fmt.Printf("Error backing up database %s: %v\n", db.Name, err)
-				continue
-			}
-			
-			// Optional compression
-			finalPath := backupFilepath
-			if compress {
-				gzPath := backupFilepath + ".gz"
-				if err := compressFile(backupFilepath, gzPath); err != nil {
-					fmt.Printf("Warning: failed to compress backup: %v\n", err)
-				} else {
-					os.Remove(backupFilepath)
-					finalPath = gzPath
-				}
-			}
-			
-			if err := uc.storageGateway.StoreBackup(finalPath, db.Name, "", ""); err != nil {
-				fmt.Printf("Error storing backup: %v\n", err)
-			}
-			
-			if err := uc.storageGateway.CleanupBackups(db.Name, retentionCount, "", ""); err != nil {
-				fmt.Printf("Error cleaning up backups: %v\n", err)
-			}
-		} else if s3Bucket != "" && s3Path != "" {
-			// S3 backup
-			localBackupPath := filepath.Join(os.TempDir(), backupFilename)
-			if err := uc.databaseGateway.BackupDatabase(db.Name, localBackupPath); err != nil {
-				fmt.Printf("Error backing up database %s: %v\n", db.Name, err)
-				continue
-			}
-			
-			finalLocalPath := localBackupPath
-			finalKeyName := backupFilename
-			
-			if compress {
-				gzLocal := localBackupPath + ".gz"
-				if err := compressFile(localBackupPath, gzLocal); err != nil {
-					fmt.Printf("Warning: failed to compress backup: %v\n", err)
-				} else {
-					os.Remove(localBackupPath)
-					finalLocalPath = gzLocal
-					finalKeyName = backupFilename + ".gz"
-				}
-			}
-			
-			s3Key := fmt.Sprintf("%s/%s/%s", s3Path, db.Name, finalKeyName)
-			if err := uc.storageGateway.StoreBackup(finalLocalPath, db.Name, s3Bucket, s3Key); err != nil {
-				fmt.Printf("Error storing backup to S3: %v\n", err)
+	// Server and dumper details are the same for every database of this run
+	var serverFlavor, serverVersion string
+	var dumper domain.DumperInfo
+	if !opts.DryRun && len(databases) > 0 {
+		serverFlavor, serverVersion, err = uc.databaseGateway.ServerVersion()
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		if opts.Type == domain.BackupTypePhysical {
+			dumper = uc.databaseGateway.PhysicalDumperInfo()
+		} else {
+			dumper = uc.databaseGateway.DumperInfo()
+		}
+	}
+	
+	if opts.DryRun {
+		for _, db := range databases {
+			now := time.Now()
+			backupFilename := backupFileName(db.Name, now, opts)
+			fmt.Printf("Would back up database %s to %s\n", db.Name, uc.storageGateway.BackupLocation(db.Name, backupFilename))
+			pending := domain.Backup{Key: db.Name + "/" + backupFilename, Time: now}
+			if err := uc.storageGateway.CleanupBackups(db.Name, opts.Retention, true, pending); err != nil {
+				fmt.Printf("Error planning cleanup: %v\n", err)
 			}
-			
-			if err := uc.storageGateway.CleanupBackups(db.Name, retentionCount, s3Bucket, s3Path); err != nil {
-				fmt.Printf("Error cleaning up S3 backups: %v\n", err)
+		}
+		return nil
+	}
+	
+	workers := opts.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(databases) {
+		workers = len(databases)
+	}
+	
+	// Each worker dumps, uploads and prunes one database at a time; results are kept
+	// in database order so the summary reads the same however the work interleaved
+	results := make([]databaseResult, len(databases))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = uc.backupDatabase(databases[idx].Name, opts, serverFlavor, serverVersion, dumper, workers > 1)
 			}
-			
-			// Clean up temporary file
-			os.Remove(finalLocalPath)
+		}()
+	}
+	for idx := range databases {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Database)
 		}
 	}
+	if workers > 1 || len(failed) > 0 {
+		printSummary(results)
+	}
 	
+	if len(failed) > 0 {
+		return fmt.Errorf("backup failed for %d database(s): %s", len(failed), strings.Join(failed, ", "))
+	}
 	return nil
 }
 
-// compressFile compresses a file using gzip
-func compressFile(srcPath string, dstPath string) error {
-	src, err := os.Open(srcPath)
-	if err != nil {
-		return err
+// databaseResult is the outcome of backing up one database
+type databaseResult struct {
+	Database string
+	File     string
+	Size     int64
+	Duration time.Duration
+	Err      error
+}
+
+// backupFileName returns the name of a backup of dbName taken at t
+func backupFileName(dbName string, t time.Time, opts BackupOptions) string {
+	extension := ".sql"
+	if dbName == data.PhysicalDatabase {
+		extension = data.PhysicalExtension
+	}
+	return archiveFileName(dbName, extension, t, opts)
+}
+
+// archiveFileName returns the name under which a file of the given type is stored,
+// e.g. "shop-20241119030000.sql.gz"
+func archiveFileName(base string, extension string, t time.Time, opts BackupOptions) string {
+	name := fmt.Sprintf("%s-%s%s%s", base, t.Format(domain.BackupTimestampFormat), extension, opts.Codec.Extension())
+	if opts.Encryption != nil {
+		name += opts.Encryption.Extension()
 	}
-	defer src.Close()
+	return name
+}
+
+// backupDatabase dumps, stores and prunes the backups of one database. It is safe to
+// run for several databases at once; with parallel set its messages are prefixed with
+// the database like those of the dump tool.
+func (uc *BackupUseCase) backupDatabase(dbName string, opts BackupOptions, serverFlavor string, serverVersion string, dumper domain.DumperInfo, parallel bool) databaseResult {
+	storageGateway := uc.storageGateway
+	if parallel {
+		out := data.NewLinePrefixWriter(os.Stdout, "["+dbName+"] ")
+		defer out.Flush()
+		storageGateway = storageGateway.WithOutput(out)
+	}
+	
+	now := time.Now()
+	backupFilename := backupFileName(dbName, now, opts)
+	result := databaseResult{Database: dbName, File: backupFilename}
+	
+	manifest := newManifest(dbName, backupFilename, now, opts)
+	manifest.Host = uc.databaseGateway.Source()
+	manifest.ServerFlavor = serverFlavor
+	manifest.ServerVersion = serverVersion
+	manifest.Dumper = dumper
 	
-	dst, err := os.Create(dstPath)
+	size, err := storeArchive(storageGateway, dbName, manifest, opts, func(w io.Writer, codec data.Codec, level int) (*data.DumpStats, error) {
+		return uc.databaseGateway.BackupDatabase(dbName, w, codec, level)
+	})
+	result.Duration = time.Since(now)
 	if err != nil {
-		return err
+		// Never prune after a failed run: the existing backups are the only good ones
+		fmt.Fprintf(storageGateway.Output(), "Error backing up database %s: %v\n", dbName, err)
+		result.Err = err
+		return result
 	}
-	defer dst.Close()
+	result.Size = size
 	
-	gzWriter := gzip.NewWriter(dst)
-	defer gzWriter.Close()
+	if err := storageGateway.CleanupBackups(dbName, opts.Retention, false); err != nil {
+		fmt.Fprintf(storageGateway.Output(), "Error cleaning up backups of %s: %v\n", dbName, err)
+	}
+	return result
+}
+
+// printSummary prints the status of every database of a run
+func printSummary(results []databaseResult) {
+	fmt.Println("\nSummary:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(tw, "FAILED\t%s\t%v\t\t%s\n", result.Database, result.Err, result.Duration.Round(time.Second))
+			continue
+		}
+		fmt.Fprintf(tw, "OK\t%s\t%s\t%s\t%s\n", result.Database, result.File, domain.FormatSize(result.Size), result.Duration.Round(time.Second))
+	}
+	tw.Flush()
+}
+
+// dumpFunc writes a dump compressed with codec to w
+type dumpFunc func(w io.Writer, codec data.Codec, level int) (*data.DumpStats, error)
+
+// storeArchive streams a dump into storage as dbName/manifest.File and stores its
+// manifest once the dump completed. It returns the stored size.
+func storeArchive(storageGateway *data.StorageGateway, dbName string, manifest *domain.Manifest, opts BackupOptions, dump dumpFunc) (int64, error) {
+	// Stream dump -> compressor -> encryptor -> storage; backends only expose the
+	// backup under its final name once the stream completed successfully
+	reader, writer := io.Pipe()
+	stored := &meteredWriter{w: writer, hash: sha256.New()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer.CloseWithError(encodeDump(stored, opts, manifest, dump))
+	}()
 	
-	_, err = io.Copy(gzWriter, src)
-	return err
+	err := storageGateway.StoreBackup(reader, dbName, manifest.File)
+	reader.CloseWithError(err)
+	<-done
+	if err != nil {
+		return 0, err
+	}
+	manifest.FinishedAt = time.Now()
+	manifest.DurationSeconds = manifest.FinishedAt.Sub(manifest.StartedAt).Seconds()
+	manifest.Size = stored.n
+	manifest.SHA256 = hex.EncodeToString(stored.hash.Sum(nil))
+	if opts.Encryption == nil {
+		manifest.CompressedSize = stored.n
+	}
+	if err := storageGateway.RecordChecksum(dbName, manifest.File, manifest.SHA256); err != nil {
+		// The manifest still has it
+		fmt.Fprintf(storageGateway.Output(), "Warning: %v\n", err)
+	}
+	if err := storageGateway.StoreManifest(dbName, manifest.File, manifest); err != nil {
+		return 0, err
+	}
+	return stored.n, nil
 }
 
+// encodeDump writes the compressed (and optionally encrypted) dump to w, recording the
+// dump's sizes, table row counts and binary log position in manifest
+func encodeDump(w io.Writer, opts BackupOptions, manifest *domain.Manifest, dump dumpFunc) error {
+	if opts.Encryption == nil {
+		stats, err := dump(w, opts.Codec, opts.CompressionLevel)
+		if err != nil {
+			return err
+		}
+		recordStats(manifest, stats)
+		return nil
+	}
+	
+	encWriter, err := opts.Encryption.NewWriter(w, opts.EncryptionConfig)
+	if err != nil {
+		return fmt.Errorf("failed to start %s encryption: %w", opts.Encryption.Name(), err)
+	}
+	compressed := &meteredWriter{w: encWriter}
+	stats, err := dump(compressed, opts.Codec, opts.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	recordStats(manifest, stats)
+	manifest.CompressedSize = compressed.n
+	return encWriter.Close()
+}
+
+// recordStats copies what was learned while dumping into manifest
+func recordStats(manifest *domain.Manifest, stats *data.DumpStats) {
+	manifest.UncompressedSize, manifest.Tables = stats.Size, stats.Tables
+	if stats.Binlog != nil {
+		manifest.Binlog = stats.Binlog
+	}
+}
+
+// newManifest starts the manifest of a backup taken at startedAt
+func newManifest(dbName string, fileName string, startedAt time.Time, opts BackupOptions) *domain.Manifest {
+	manifest := &domain.Manifest{
+		ManifestVersion:  domain.ManifestVersion,
+		ToolVersion:      opts.ToolVersion,
+		Connection:       opts.Connection,
+		Database:         dbName,
+		Type:             domain.BackupTypeLogical,
+		File:             fileName,
+		StartedAt:        startedAt,
+		Compression:      opts.Codec.Name(),
+		CompressionLevel: opts.CompressionLevel,
+	}
+	switch dbName {
+	case data.PhysicalDatabase:
+		manifest.Type = domain.BackupTypePhysical
+	case data.BinlogDatabase:
+		manifest.Type = domain.BackupTypeBinlog
+	}
+	if opts.Encryption != nil {
+		manifest.Encryption = &domain.EncryptionInfo{
+			Mode:       opts.Encryption.Name(),
+			Recipients: opts.EncryptionConfig.Recipients,
+		}
+		if opts.Encryption.Name() == "aes" {
+			manifest.Encryption.KDF = strings.ToLower(opts.EncryptionConfig.KDF)
+			if manifest.Encryption.KDF == "" {
+				manifest.Encryption.KDF = "scrypt"
+			}
+		}
+	}
+	return manifest
+}
+
+// meteredWriter counts, and optionally hashes, the bytes written through it
+type meteredWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	n    int64
+}
+
+func (m *meteredWriter) Write(p []byte) (int, error) {
+	n, err := m.w.Write(p)
+	if m.hash != nil {
+		m.hash.Write(p[:n])
+	}
+	m.n += int64(n)
+	return n, err
+}
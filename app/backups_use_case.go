@@ -0,0 +1,172 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// BackupDetails describes a stored backup
+type BackupDetails struct {
+	ID          string     `json:"id"`
+	Database    string     `json:"database"`
+	Name        string     `json:"name"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Size        int64      `json:"size"`
+	Type        string     `json:"type"`
+	Compression string     `json:"compression"`
+	Encryption  string     `json:"encryption,omitempty"`
+	Location    string     `json:"location"`
+	Checksum    string     `json:"checksum,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LegalHold   bool       `json:"legal_hold,omitempty"`
+	// Manifest is nil for backups made before manifests were written
+	Manifest *domain.Manifest `json:"manifest,omitempty"`
+}
+
+// BackupFilter selects backups to list; empty fields match everything
+type BackupFilter struct {
+	Database string
+	Since    time.Time
+}
+
+// BackupsUseCase lists, inspects and deletes stored backups
+type BackupsUseCase struct {
+	storageGateway *data.StorageGateway
+}
+
+// NewBackupsUseCase creates a new BackupsUseCase instance
+func NewBackupsUseCase(storageGateway *data.StorageGateway) *BackupsUseCase {
+	return &BackupsUseCase{
+		storageGateway: storageGateway,
+	}
+}
+
+// List returns the stored backups matching filter (newest first)
+func (uc *BackupsUseCase) List(filter BackupFilter) ([]BackupDetails, error) {
+	var objects []data.ObjectInfo
+	var err error
+	if filter.Database != "" {
+		objects, err = uc.storageGateway.ListBackups(filter.Database)
+	} else {
+		objects, err = uc.storageGateway.ListAllBackups()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []BackupDetails
+	for _, obj := range objects {
+		details := uc.describe(obj)
+		if !filter.Since.IsZero() && details.Timestamp.Before(filter.Since) {
+			continue
+		}
+		backups = append(backups, details)
+	}
+	return backups, nil
+}
+
+// Show returns the details of a single backup, including its lock status
+func (uc *BackupsUseCase) Show(id string) (*BackupDetails, error) {
+	key, err := uc.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := uc.storageGateway.StatBackup(key)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s not found: %w", id, err)
+	}
+
+	details := uc.describe(*obj)
+	if lock := uc.storageGateway.LockInfo(key); lock != nil {
+		if !lock.RetainUntil.IsZero() {
+			details.LockedUntil = &lock.RetainUntil
+		}
+		details.LegalHold = lock.LegalHold
+	}
+	return &details, nil
+}
+
+// Delete deletes a single backup; locked backups are refused
+func (uc *BackupsUseCase) Delete(id string) error {
+	key, err := uc.resolve(id)
+	if err != nil {
+		return err
+	}
+	if err := uc.storageGateway.DeleteBackup(key); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted backup: %s\n", uc.storageGateway.Location(key))
+	return nil
+}
+
+// resolve turns a backup ID ("<db>/<file>") or a bare file name into its storage key
+func (uc *BackupsUseCase) resolve(id string) (string, error) {
+	id = strings.Trim(id, "/")
+	if strings.Contains(id, "/") {
+		return id, nil
+	}
+
+	objects, err := uc.storageGateway.ListAllBackups()
+	if err != nil {
+		return "", fmt.Errorf("failed to list backups: %w", err)
+	}
+	var matches []string
+	for _, obj := range objects {
+		if path.Base(obj.Key) == id {
+			matches = append(matches, obj.Key)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("backup %s not found", id)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("backup name %s is ambiguous, use one of: %s", id, strings.Join(matches, ", "))
+}
+
+// describe builds the details of a stored backup from its manifest, falling back
+// to its file name and storage info for backups without one
+func (uc *BackupsUseCase) describe(obj data.ObjectInfo) BackupDetails {
+	name := path.Base(obj.Key)
+	details := BackupDetails{
+		ID:          obj.Key,
+		Database:    path.Dir(obj.Key),
+		Name:        name,
+		Timestamp:   data.BackupTime(obj),
+		Size:        obj.Size,
+		Type:        data.BackupTypeForFile(name),
+		Compression: data.CodecForFile(name).Name(),
+		Location:    uc.storageGateway.Location(obj.Key),
+	}
+	if encryption := data.EncryptionForFile(name); encryption != nil {
+		details.Encryption = encryption.Name()
+	}
+	if obj.ETag != "" {
+		details.Checksum = "etag:" + obj.ETag
+	}
+
+	manifest, err := uc.storageGateway.LoadManifest(obj.Key)
+	if err != nil {
+		return details
+	}
+	details.Manifest = manifest
+	details.Timestamp = manifest.StartedAt
+	details.Compression = manifest.Compression
+	if manifest.Type != "" {
+		details.Type = manifest.Type
+	}
+	details.Encryption = ""
+	if manifest.Encryption != nil {
+		details.Encryption = manifest.Encryption.Mode
+	}
+	if manifest.SHA256 != "" {
+		details.Checksum = "sha256:" + manifest.SHA256
+	}
+	return details
+}
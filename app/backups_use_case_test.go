@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// newTestBackupsUseCase stores an empty file under every key in a local storage
+func newTestBackupsUseCase(t *testing.T, keys ...string) *BackupsUseCase {
+	t.Helper()
+	storage, err := data.NewStorage(data.StorageConfig{Driver: "local", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := storage.Put(context.Background(), key, strings.NewReader("dump")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewBackupsUseCase(data.NewStorageGateway(storage))
+}
+
+func backupIDs(backups []BackupDetails) string {
+	ids := make([]string, len(backups))
+	for i, backup := range backups {
+		ids[i] = backup.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestBackupsList(t *testing.T) {
+	uc := newTestBackupsUseCase(t,
+		"shop/shop-20261014030000.sql.gz",
+		"shop/shop-20261016030000.sql.zst.age",
+		"blog/blog-20261015030000.sql",
+		"blog/notes.txt",
+		"stray-20261016030000.sql.gz",
+	)
+
+	tests := []struct {
+		name   string
+		filter BackupFilter
+		want   string
+	}{
+		{"all databases", BackupFilter{}, "shop/shop-20261016030000.sql.zst.age,blog/blog-20261015030000.sql,shop/shop-20261014030000.sql.gz"},
+		{"one database", BackupFilter{Database: "shop"}, "shop/shop-20261016030000.sql.zst.age,shop/shop-20261014030000.sql.gz"},
+		{"since", BackupFilter{Since: time.Date(2026, 10, 15, 0, 0, 0, 0, time.Local)}, "shop/shop-20261016030000.sql.zst.age,blog/blog-20261015030000.sql"},
+	}
+	for _, tt := range tests {
+		backups, err := uc.List(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := backupIDs(backups); got != tt.want {
+			t.Errorf("%s: List = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	backups, _ := uc.List(BackupFilter{Database: "shop"})
+	if newest := backups[0]; newest.Database != "shop" || newest.Compression != "zstd" || newest.Encryption != "age" || newest.Size != 4 {
+		t.Errorf("describe = %+v", newest)
+	}
+}
+
+func TestBackupsShowAndDelete(t *testing.T) {
+	uc := newTestBackupsUseCase(t,
+		"shop/shop-20261016030000.sql.gz",
+		"shop/shop-20261015030000.sql.gz",
+		"shop-staging/shop-20261015030000.sql.gz",
+	)
+
+	// A bare file name resolves when it is unique
+	details, err := uc.Show("shop-20261016030000.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.ID != "shop/shop-20261016030000.sql.gz" || details.Compression != "gzip" {
+		t.Errorf("Show = %+v", details)
+	}
+
+	if _, err := uc.Show("shop-20261015030000.sql.gz"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Show(ambiguous name) error = %v", err)
+	}
+	if _, err := uc.Show("shop-20261001030000.sql.gz"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Show(missing name) error = %v", err)
+	}
+
+	if err := uc.Delete("shop/shop-20261015030000.sql.gz"); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := uc.List(BackupFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := backupIDs(backups), "shop/shop-20261016030000.sql.gz,shop-staging/shop-20261015030000.sql.gz"; got != want {
+		t.Errorf("after Delete: %s, want %s", got, want)
+	}
+}
+
+func TestBackupsDescribeUsesManifest(t *testing.T) {
+	uc := newTestBackupsUseCase(t, "shop/shop-20261016030000.sql.gz")
+	started := time.Date(2026, 10, 16, 3, 0, 5, 0, time.UTC)
+	manifest := &domain.Manifest{Database: "shop", File: "shop-20261016030000.sql.gz", StartedAt: started, SHA256: "abc123", Compression: "zstd"}
+	if err := uc.storageGateway.StoreManifest("shop", manifest.File, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := uc.Show("shop/shop-20261016030000.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !details.Timestamp.Equal(started) || details.Compression != "zstd" || details.Checksum != "sha256:abc123" || details.Manifest == nil {
+		t.Errorf("Show = %+v", details)
+	}
+}
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// BinlogUseCase archives the binary logs of a server for point-in-time recovery and
+// prunes those no retained backup needs anymore
+type BinlogUseCase struct {
+	databaseGateway *data.DatabaseGateway
+	storageGateway  *data.StorageGateway
+}
+
+// NewBinlogUseCase creates a new BinlogUseCase instance
+func NewBinlogUseCase(databaseGateway *data.DatabaseGateway, storageGateway *data.StorageGateway) *BinlogUseCase {
+	return &BinlogUseCase{
+		databaseGateway: databaseGateway,
+		storageGateway:  storageGateway,
+	}
+}
+
+// BinlogOptions configures archiving binary logs. Compression, encryption and the
+// manifest settings are taken from the embedded BackupOptions; retention and
+// parallelism don't apply.
+type BinlogOptions struct {
+	BackupOptions
+	// Flush closes the binary log being written first, so that it is archived too
+	Flush bool
+	// Interval repeats archiving until the process is stopped; zero archives once
+	Interval time.Duration
+}
+
+// Execute archives every closed binary log that isn't stored yet, then deletes archived
+// binary logs older than the one the oldest retained dump starts from
+func (uc *BinlogUseCase) Execute(opts BinlogOptions) error {
+	if err := uc.databaseGateway.CheckBinlogs(); err != nil {
+		return err
+	}
+	for {
+		err := uc.archive(opts)
+		if opts.Interval <= 0 {
+			return err
+		}
+		// Keep going: the next round catches up on what failed
+		if err != nil {
+			fmt.Printf("Error archiving binary logs: %v\n", err)
+		}
+		time.Sleep(opts.Interval)
+	}
+}
+
+// archive runs one round of archiving and pruning
+func (uc *BinlogUseCase) archive(opts BinlogOptions) error {
+	if opts.Flush {
+		if err := uc.databaseGateway.FlushBinlogs(); err != nil {
+			return err
+		}
+	}
+	names, err := uc.databaseGateway.ListBinlogs()
+	if err != nil {
+		return err
+	}
+	oldest, err := uc.oldestNeededBinlog()
+	if err != nil {
+		return err
+	}
+	archived, err := listArchivedBinlogs(uc.storageGateway)
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(archived))
+	for _, binlog := range archived {
+		stored[binlog.Name] = true
+	}
+
+	// The last binary log is still being written and is archived once it is closed.
+	// Those from before the oldest retained dump would only be pruned again.
+	var pending []string
+	for i, name := range names {
+		if i < len(names)-1 && !stored[name] && (oldest == "" || compareBinlogs(name, oldest) >= 0) {
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("No new binary logs to archive")
+	} else {
+		flavor, version, err := uc.databaseGateway.ServerVersion()
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		dumper := uc.databaseGateway.BinlogDumperInfo()
+		for _, name := range pending {
+			if err := uc.archiveBinlog(name, opts.BackupOptions, flavor, version, dumper); err != nil {
+				return fmt.Errorf("failed to archive binary log %s: %w", name, err)
+			}
+		}
+	}
+
+	return uc.prune(oldest)
+}
+
+// archiveBinlog stores one binary log with its manifest
+func (uc *BinlogUseCase) archiveBinlog(name string, opts BackupOptions, serverFlavor string, serverVersion string, dumper domain.DumperInfo) error {
+	now := time.Now()
+	manifest := newManifest(data.BinlogDatabase, archiveFileName(name, data.BinlogExtension, now, opts), now, opts)
+	manifest.Host = uc.databaseGateway.Source()
+	manifest.ServerFlavor = serverFlavor
+	manifest.ServerVersion = serverVersion
+	manifest.Dumper = dumper
+	manifest.Binlog = &domain.BinlogPosition{File: name}
+
+	_, err := storeArchive(uc.storageGateway, data.BinlogDatabase, manifest, opts, func(w io.Writer, codec data.Codec, level int) (*data.DumpStats, error) {
+		return uc.databaseGateway.BackupBinlog(name, w, codec, level)
+	})
+	return err
+}
+
+// archivedBinlog is a binary log in storage
+type archivedBinlog struct {
+	Name string
+	Obj  data.ObjectInfo
+	// FirstEvent and LastEvent are taken from the manifest when needed; nil if unknown
+	FirstEvent *time.Time
+	LastEvent  *time.Time
+}
+
+// oldestNeededBinlog returns the binary log the oldest retained dump starts from, or ""
+// when no dump has a binary log position
+func (uc *BinlogUseCase) oldestNeededBinlog() (string, error) {
+	objects, err := uc.storageGateway.ListAllBackups()
+	if err != nil {
+		return "", fmt.Errorf("failed to list backups: %w", err)
+	}
+	oldest := ""
+	for _, obj := range objects {
+		dbName := path.Dir(obj.Key)
+		if dbName == data.BinlogDatabase || dbName == data.PhysicalDatabase || dbName == data.GlobalsDatabase {
+			continue
+		}
+		manifest, err := uc.storageGateway.LoadManifest(obj.Key)
+		if err != nil || manifest.Binlog == nil || manifest.Binlog.File == "" {
+			continue
+		}
+		if oldest == "" || compareBinlogs(manifest.Binlog.File, oldest) < 0 {
+			oldest = manifest.Binlog.File
+		}
+	}
+	return oldest, nil
+}
+
+// prune deletes archived binary logs from before oldest. Nothing is deleted while no
+// dump has a binary log position.
+func (uc *BinlogUseCase) prune(oldest string) error {
+	if oldest == "" {
+		return nil
+	}
+
+	archived, err := listArchivedBinlogs(uc.storageGateway)
+	if err != nil {
+		return err
+	}
+	for _, binlog := range archived {
+		if compareBinlogs(binlog.Name, oldest) >= 0 {
+			break
+		}
+		if err := uc.storageGateway.DeleteBackup(binlog.Obj.Key); err != nil {
+			fmt.Printf("Error removing binary log %s: %v\n", binlog.Name, err)
+			continue
+		}
+		fmt.Printf("Removed old binary log: %s\n", uc.storageGateway.Location(binlog.Obj.Key))
+	}
+	return nil
+}
+
+// listArchivedBinlogs returns the binary logs archived in storage in binary log order
+func listArchivedBinlogs(storageGateway *data.StorageGateway) ([]archivedBinlog, error) {
+	objects, err := storageGateway.ListBackups(data.BinlogDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	binlogs := make([]archivedBinlog, 0, len(objects))
+	for _, obj := range objects {
+		binlogs = append(binlogs, archivedBinlog{Name: binlogName(path.Base(obj.Key)), Obj: obj})
+	}
+	sort.SliceStable(binlogs, func(i, j int) bool {
+		return compareBinlogs(binlogs[i].Name, binlogs[j].Name) < 0
+	})
+	return binlogs, nil
+}
+
+// binlogName returns the binary log an archive file holds, e.g. "binlog.000042" for
+// "binlog.000042-20241119030000.binlog.gz"
+func binlogName(fileName string) string {
+	if i := strings.LastIndex(fileName, "-"); i >= 0 {
+		return fileName[:i]
+	}
+	return fileName
+}
+
+// binlogSequence splits a binary log name into its base name and sequence number
+func binlogSequence(name string) (string, int64) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return name, -1
+	}
+	seq, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return name, -1
+	}
+	return name[:i], seq
+}
+
+// compareBinlogs orders binary logs by their sequence numbers
+func compareBinlogs(a string, b string) int {
+	baseA, seqA := binlogSequence(a)
+	baseB, seqB := binlogSequence(b)
+	switch {
+	case baseA != baseB:
+		return strings.Compare(baseA, baseB)
+	case seqA < seqB:
+		return -1
+	case seqA > seqB:
+		return 1
+	}
+	return 0
+}
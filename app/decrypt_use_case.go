@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+)
+
+// DecryptUseCase decrypts a downloaded backup file without touching databases or storage
+type DecryptUseCase struct {
+	encryptionConfig data.EncryptionConfig
+}
+
+// NewDecryptUseCase creates a new DecryptUseCase instance
+func NewDecryptUseCase(encryptionConfig data.EncryptionConfig) *DecryptUseCase {
+	return &DecryptUseCase{encryptionConfig: encryptionConfig}
+}
+
+// Execute decrypts inputPath into outputPath ("-" for stdout). When decompress is set the
+// output is also decompressed to plain SQL. An empty outputPath strips the extensions from inputPath.
+func (uc *DecryptUseCase) Execute(inputPath string, outputPath string, decompress bool) error {
+	name := filepath.Base(inputPath)
+	encryption := data.EncryptionForFile(name)
+	if encryption == nil {
+		return fmt.Errorf("%s has no known encryption extension", name)
+	}
+
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(inputPath, encryption.Extension())
+		if decompress {
+			outputPath = strings.TrimSuffix(outputPath, data.CodecForFile(name).Extension())
+		}
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer input.Close()
+
+	var reader io.Reader
+	if decompress {
+		decoded, err := data.DecodeBackup(input, name, uc.encryptionConfig)
+		if err != nil {
+			return err
+		}
+		defer decoded.Close()
+		reader = decoded
+	} else {
+		reader, err = data.DecryptBackup(input, name, uc.encryptionConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if outputPath == "-" {
+		if _, err := io.Copy(os.Stdout, reader); err != nil {
+			return fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+		return nil
+	}
+
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if _, err := io.Copy(output, reader); err != nil {
+		output.Close()
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	fmt.Printf("Decrypted %s to %s\n", inputPath, outputPath)
+	return nil
+}
@@ -0,0 +1,357 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// RestoreUseCase orchestrates restoring a stored backup into a database
+type RestoreUseCase struct {
+	databaseGateway  *data.DatabaseGateway
+	storageGateway   *data.StorageGateway
+	encryptionConfig data.EncryptionConfig
+}
+
+// NewRestoreUseCase creates a new RestoreUseCase instance; encryptionConfig
+// supplies the identity used to decrypt encrypted backups
+func NewRestoreUseCase(databaseGateway *data.DatabaseGateway, storageGateway *data.StorageGateway,
+	encryptionConfig data.EncryptionConfig) *RestoreUseCase {
+	return &RestoreUseCase{
+		databaseGateway:  databaseGateway,
+		storageGateway:   storageGateway,
+		encryptionConfig: encryptionConfig,
+	}
+}
+
+// Execute restores backupName (or the latest backup when empty) of dbName into targetDB.
+// A non-empty target database is only overwritten when force is set.
+func (uc *RestoreUseCase) Execute(dbName string, targetDB string, backupName string, force bool) error {
+	if targetDB == "" {
+		targetDB = dbName
+	}
+
+	backupName, err := uc.resolveBackup(dbName, backupName)
+	if err != nil {
+		return err
+	}
+
+	empty, err := uc.databaseGateway.IsDatabaseEmpty(targetDB)
+	if err != nil {
+		return err
+	}
+	if !empty && !force {
+		return fmt.Errorf("database %s is not empty. Use --force to overwrite it", targetDB)
+	}
+
+	fmt.Printf("Restoring %s into database %s...\n", backupName, targetDB)
+
+	// The backup is opened and its decoding set up before the target database is
+	// created, so that an unreadable backup leaves nothing behind
+	manifest, src, err := uc.openBackup(dbName, backupName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if manifest != nil {
+		fmt.Printf("Backup of %s taken %s from %s\n", manifest.Database,
+			manifest.StartedAt.Local().Format("2006-01-02 15:04:05"), manifest.Host)
+	}
+
+	if err := uc.databaseGateway.CreateDatabase(targetDB); err != nil {
+		return err
+	}
+	if err := uc.databaseGateway.RestoreDatabase(targetDB, src); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully restored %s into database %s\n", backupName, targetDB)
+	return nil
+}
+
+// ExecutePointInTime restores dbName into targetDB as it was at t: the latest backup taken
+// before t with a binary log position, followed by the archived binary logs up to t.
+// A non-empty target database is only overwritten when force is set.
+func (uc *RestoreUseCase) ExecutePointInTime(dbName string, targetDB string, t time.Time, force bool) error {
+	if err := uc.databaseGateway.CheckBinlogs(); err != nil {
+		return err
+	}
+	if targetDB == "" {
+		targetDB = dbName
+	}
+
+	// Everything needed is looked up before the target database is touched
+	backupName, position, err := uc.pointInTimeBackup(dbName, t)
+	if err != nil {
+		return err
+	}
+	binlogs, err := binlogsUntil(uc.storageGateway, position.File, t)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restoring database %s as of %s from %s and %d binary log(s)\n",
+		dbName, t.Format("2006-01-02 15:04:05"), backupName, len(binlogs))
+
+	if err := uc.Execute(dbName, targetDB, backupName, force); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "db-backup-binlog-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	files := make([]string, 0, len(binlogs))
+	for _, binlog := range binlogs {
+		file := filepath.Join(dir, binlog.Name)
+		if err := uc.downloadBinlog(binlog.Obj, file); err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	fmt.Printf("Applying binary logs %s to %s from position %d...\n", binlogs[0].Name, binlogs[len(binlogs)-1].Name, position.Position)
+	if err := uc.databaseGateway.ReplayBinlogs(dbName, targetDB, files, position.Position, t); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully restored database %s into %s as of %s\n", dbName, targetDB, t.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// pointInTimeBackup returns the latest backup of dbName started before t that recorded a
+// binary log position, and that position
+func (uc *RestoreUseCase) pointInTimeBackup(dbName string, t time.Time) (string, *domain.BinlogPosition, error) {
+	backups, err := uc.storageGateway.ListBackups(dbName)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	for _, obj := range backups {
+		manifest, err := uc.storageGateway.LoadManifest(obj.Key)
+		if err != nil || manifest.Binlog == nil || manifest.Binlog.File == "" {
+			continue
+		}
+		if !manifest.StartedAt.After(t) {
+			return path.Base(obj.Key), manifest.Binlog, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no backup of %s with a binary log position was taken before %s", dbName, t.Format("2006-01-02 15:04:05"))
+}
+
+// binlogsUntil returns the archived binary logs from start on that hold the events up to
+// t, reading their event times from their manifests
+func binlogsUntil(storageGateway *data.StorageGateway, start string, t time.Time) ([]archivedBinlog, error) {
+	archived, err := listArchivedBinlogs(storageGateway)
+	if err != nil {
+		return nil, err
+	}
+	for i := range archived {
+		if compareBinlogs(archived[i].Name, start) < 0 {
+			continue
+		}
+		manifest, err := storageGateway.LoadManifest(archived[i].Obj.Key)
+		if err != nil || manifest.Binlog == nil {
+			continue
+		}
+		archived[i].FirstEvent, archived[i].LastEvent = manifest.Binlog.FirstEvent, manifest.Binlog.LastEvent
+		// Later binary logs aren't needed
+		if archived[i].LastEvent != nil && archived[i].LastEvent.After(t) {
+			break
+		}
+	}
+	return selectBinlogs(archived, start, t)
+}
+
+// selectBinlogs returns the binary logs of archived (sorted by name) from start on up to
+// the first one with events after t. Events in the second of t may continue in the next
+// binary log, so only a later last event proves that the events up to t are complete, as
+// does a next binary log that starts after t. Gaps in the archive are an error.
+//
+// Binary logs archived before their event times were recorded only tell when they were
+// archived. If the newest of them was archived after t, all of them are returned and
+// replaying stops at t; events up to t may still be missing if it ended before t.
+func selectBinlogs(archived []archivedBinlog, start string, t time.Time) ([]archivedBinlog, error) {
+	var binlogs []archivedBinlog
+	for _, binlog := range archived {
+		if compareBinlogs(binlog.Name, start) < 0 {
+			continue
+		}
+		if len(binlogs) == 0 {
+			if binlog.Name != start {
+				return nil, fmt.Errorf("binary log %s is not archived", start)
+			}
+		} else {
+			prev := binlogs[len(binlogs)-1].Name
+			if binlog.Name == prev {
+				continue
+			}
+			prevBase, prevSeq := binlogSequence(prev)
+			if base, seq := binlogSequence(binlog.Name); base != prevBase || seq != prevSeq+1 {
+				return nil, fmt.Errorf("binary logs after %s are missing from the archive", prev)
+			}
+			if binlog.FirstEvent != nil && binlog.FirstEvent.After(t) {
+				return binlogs, nil
+			}
+		}
+		binlogs = append(binlogs, binlog)
+		if binlog.LastEvent != nil && binlog.LastEvent.After(t) {
+			return binlogs, nil
+		}
+	}
+	if len(binlogs) == 0 {
+		return nil, fmt.Errorf("binary log %s is not archived", start)
+	}
+	last := binlogs[len(binlogs)-1]
+	if last.LastEvent == nil && data.BackupTime(last.Obj).After(t) {
+		return binlogs, nil
+	}
+	until := data.BackupTime(last.Obj)
+	if last.LastEvent != nil {
+		until = *last.LastEvent
+	}
+	return nil, fmt.Errorf("binary logs are only archived up to %s (%s); run the binlog command with --flush to archive the current one",
+		until.Format("2006-01-02 15:04:05"), last.Name)
+}
+
+// downloadBinlog decodes an archived binary log into file
+func (uc *RestoreUseCase) downloadBinlog(obj data.ObjectInfo, file string) error {
+	name := path.Base(obj.Key)
+	manifest, err := uc.storageGateway.LoadManifest(obj.Key)
+	if err != nil {
+		manifest = nil
+	}
+	codec, encryption, err := data.BackupFormat(name, manifest)
+	if err != nil {
+		return err
+	}
+
+	reader, err := uc.storageGateway.OpenBackup(data.BinlogDatabase, name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	src, err := data.DecodeStream(reader, codec, encryption, uc.encryptionConfig)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to read binary log %s: %w", name, err)
+	}
+	return out.Close()
+}
+
+// ExecutePhysical extracts and prepares backupName (or the latest physical backup when
+// empty) in targetDir on the database host. With copyBack the prepared files are copied
+// into the data directory of the stopped server.
+func (uc *RestoreUseCase) ExecutePhysical(backupName string, targetDir string, copyBack bool) error {
+	if err := uc.databaseGateway.CheckPhysical(); err != nil {
+		return err
+	}
+	dbName := data.PhysicalDatabase
+	backupName, err := uc.resolveBackup(dbName, backupName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring physical backup %s into %s...\n", backupName, targetDir)
+
+	// The backup has to be prepared with the tool matching the server it was taken from
+	manifest, src, err := uc.openBackup(dbName, backupName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	var flavor, version string
+	if manifest != nil {
+		flavor, version = manifest.ServerFlavor, manifest.ServerVersion
+		fmt.Printf("Backup taken %s from %s\n",
+			manifest.StartedAt.Local().Format("2006-01-02 15:04:05"), manifest.Host)
+	}
+
+	if err := uc.databaseGateway.RestorePhysical(src, targetDir, flavor, version, copyBack); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully prepared %s in %s\n", backupName, targetDir)
+	var steps []string
+	if !copyBack {
+		steps = append(steps, "Stop the server and empty its data directory",
+			fmt.Sprintf("%s --copy-back --target-dir=%s", uc.databaseGateway.PhysicalTool(flavor, version), targetDir))
+	}
+	steps = append(steps, "chown -R mysql:mysql <datadir>", "Start the server")
+	fmt.Println("Next steps:")
+	for i, step := range steps {
+		fmt.Printf("  %d. %s\n", i+1, step)
+	}
+	return nil
+}
+
+// resolveBackup returns the file name of backupName, which must exist, or of the latest
+// backup of dbName when it is empty
+func (uc *RestoreUseCase) resolveBackup(dbName string, backupName string) (string, error) {
+	if backupName != "" {
+		backupName = path.Base(backupName)
+		if _, err := uc.storageGateway.StatBackup(path.Join(dbName, backupName)); err != nil {
+			return "", fmt.Errorf("backup %s of database %s not found: %w", backupName, dbName, err)
+		}
+		return backupName, nil
+	}
+	backups, err := uc.storageGateway.ListBackups(dbName)
+	if err != nil {
+		return "", fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups found for database %s", dbName)
+	}
+	return path.Base(backups[0].Key), nil
+}
+
+// openBackup opens a stored backup and returns its decrypted and decompressed content,
+// along with its manifest (nil for backups made before manifests were written)
+func (uc *RestoreUseCase) openBackup(dbName string, backupName string) (*domain.Manifest, io.ReadCloser, error) {
+	// The manifest describes how the backup was written; older backups without one
+	// are decoded based on their file name
+	manifest, err := uc.storageGateway.LoadManifest(path.Join(dbName, backupName))
+	if err != nil {
+		manifest = nil
+	}
+	codec, encryption, err := data.BackupFormat(backupName, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := uc.storageGateway.OpenBackup(dbName, backupName)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := data.DecodeStream(reader, codec, encryption, uc.encryptionConfig)
+	if err != nil {
+		reader.Close()
+		return nil, nil, err
+	}
+	return manifest, &decodedBackup{ReadCloser: src, stored: reader}, nil
+}
+
+// decodedBackup reads a decoded backup and closes the stored backup along with the decoder
+type decodedBackup struct {
+	io.ReadCloser
+	stored io.Closer
+}
+
+func (d *decodedBackup) Close() error {
+	d.ReadCloser.Close()
+	return d.stored.Close()
+}
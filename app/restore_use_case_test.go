@@ -0,0 +1,250 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+const testTimeFormat = "2006-01-02 15:04:05"
+
+func testTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation(testTimeFormat, s, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// testBinlog returns an archived binary log with the given archive time and, unless
+// empty, first and last event times
+func testBinlog(t *testing.T, name string, archived string, first string, last string) archivedBinlog {
+	t.Helper()
+	archivedAt := testTime(t, archived)
+	binlog := archivedBinlog{
+		Name: name,
+		Obj:  data.ObjectInfo{Key: data.BinlogDatabase + "/" + name + "-" + archivedAt.Format(domain.BackupTimestampFormat) + ".binlog.gz"},
+	}
+	if first != "" {
+		firstEvent, lastEvent := testTime(t, first), testTime(t, last)
+		binlog.FirstEvent, binlog.LastEvent = &firstEvent, &lastEvent
+	}
+	return binlog
+}
+
+func binlogNames(binlogs []archivedBinlog) []string {
+	names := make([]string, len(binlogs))
+	for i, binlog := range binlogs {
+		names[i] = binlog.Name
+	}
+	return names
+}
+
+func TestSelectBinlogs(t *testing.T) {
+	// Each binary log is rotated at the full hour and archived five minutes later
+	archived := []archivedBinlog{
+		testBinlog(t, "binlog.000009", "2026-10-16 10:05:00", "2026-10-16 09:00:00", "2026-10-16 10:00:00"),
+		testBinlog(t, "binlog.000010", "2026-10-16 11:05:00", "2026-10-16 10:00:00", "2026-10-16 11:00:00"),
+		testBinlog(t, "binlog.000011", "2026-10-16 12:05:00", "2026-10-16 11:00:00", "2026-10-16 12:00:00"),
+		testBinlog(t, "binlog.000012", "2026-10-16 13:05:00", "2026-10-16 12:00:00", "2026-10-16 13:00:00"),
+	}
+
+	tests := []struct {
+		name   string
+		start  string
+		target string
+		want   []string
+	}{
+		{"within the start binary log", "binlog.000010", "2026-10-16 10:30:00", []string{"binlog.000010"}},
+		// binlog.000010 was archived after the target but ended before it
+		{"between rotation and archiving", "binlog.000010", "2026-10-16 11:03:00", []string{"binlog.000010", "binlog.000011"}},
+		// Events in the second of the rotation may be in either binary log
+		{"at the rotation", "binlog.000010", "2026-10-16 11:00:00", []string{"binlog.000010", "binlog.000011"}},
+		{"several binary logs", "binlog.000009", "2026-10-16 12:59:59", []string{"binlog.000009", "binlog.000010", "binlog.000011", "binlog.000012"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binlogs, err := selectBinlogs(archived, tt.start, testTime(t, tt.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := binlogNames(binlogs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectBinlogsErrors(t *testing.T) {
+	archived := []archivedBinlog{
+		testBinlog(t, "binlog.000010", "2026-10-16 11:05:00", "2026-10-16 10:00:00", "2026-10-16 11:00:00"),
+		testBinlog(t, "binlog.000011", "2026-10-16 12:05:00", "2026-10-16 11:00:00", "2026-10-16 12:00:00"),
+		testBinlog(t, "binlog.000013", "2026-10-16 14:05:00", "2026-10-16 13:00:00", "2026-10-16 14:00:00"),
+	}
+
+	tests := []struct {
+		name   string
+		start  string
+		target string
+		want   string
+	}{
+		{"start not archived", "binlog.000009", "2026-10-16 10:30:00", "binary log binlog.000009 is not archived"},
+		{"start pruned", "binlog.000012", "2026-10-16 12:30:00", "binary log binlog.000012 is not archived"},
+		{"gap", "binlog.000010", "2026-10-16 12:30:00", "binary logs after binlog.000011 are missing"},
+		{"not archived yet", "binlog.000013", "2026-10-16 14:30:00", "only archived up to 2026-10-16 14:00:00 (binlog.000013)"},
+		{"rotated but not archived", "binlog.000013", "2026-10-16 14:00:00", "only archived up to 2026-10-16 14:00:00 (binlog.000013)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binlogs, err := selectBinlogs(archived, tt.start, testTime(t, tt.target))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v and %v, want an error containing %q", binlogNames(binlogs), err, tt.want)
+			}
+		})
+	}
+
+	// The gap doesn't matter when the events up to the target are before it
+	binlogs, err := selectBinlogs(archived, "binlog.000010", testTime(t, "2026-10-16 11:30:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binlogNames(binlogs); !reflect.DeepEqual(got, []string{"binlog.000010", "binlog.000011"}) {
+		t.Errorf("before the gap: got %v", got)
+	}
+}
+
+func TestSelectBinlogsSkipsBinlogAfterTarget(t *testing.T) {
+	// The server was stopped between the binary logs
+	archived := []archivedBinlog{
+		testBinlog(t, "binlog.000010", "2026-10-16 11:05:00", "2026-10-16 10:00:00", "2026-10-16 10:40:00"),
+		testBinlog(t, "binlog.000011", "2026-10-16 12:05:00", "2026-10-16 11:00:00", "2026-10-16 12:00:00"),
+	}
+	binlogs, err := selectBinlogs(archived, "binlog.000010", testTime(t, "2026-10-16 10:50:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binlogNames(binlogs); !reflect.DeepEqual(got, []string{"binlog.000010"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestSelectBinlogsWithoutEventTimes(t *testing.T) {
+	archived := []archivedBinlog{
+		testBinlog(t, "binlog.000010", "2026-10-16 11:05:00", "", ""),
+		testBinlog(t, "binlog.000011", "2026-10-16 12:05:00", "", ""),
+		testBinlog(t, "binlog.000012", "2026-10-16 13:05:00", "2026-10-16 12:00:00", "2026-10-16 13:00:00"),
+	}
+
+	// Without event times every contiguous binary log is applied
+	binlogs, err := selectBinlogs(archived[:2], "binlog.000010", testTime(t, "2026-10-16 10:30:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binlogNames(binlogs); !reflect.DeepEqual(got, []string{"binlog.000010", "binlog.000011"}) {
+		t.Errorf("got %v", got)
+	}
+	// until one with event times covers the target
+	binlogs, err = selectBinlogs(archived, "binlog.000010", testTime(t, "2026-10-16 12:30:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binlogNames(binlogs); !reflect.DeepEqual(got, []string{"binlog.000010", "binlog.000011", "binlog.000012"}) {
+		t.Errorf("got %v", got)
+	}
+
+	_, err = selectBinlogs(archived[:2], "binlog.000010", testTime(t, "2026-10-16 12:30:00"))
+	if err == nil || !strings.Contains(err.Error(), "only archived up to 2026-10-16 12:05:00 (binlog.000011)") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+// fakeEngine records the databases created and restored by a restore
+type fakeEngine struct {
+	data.Engine
+	created  []string
+	restored map[string]string
+}
+
+func (e *fakeEngine) Name() string              { return "fake" }
+func (e *fakeEngine) DefaultPort() int          { return 3306 }
+func (e *fakeEngine) SystemDatabases() []string { return nil }
+
+func (e *fakeEngine) IsDatabaseEmpty(ep data.Endpoint, dbName string) (bool, error) {
+	return true, nil
+}
+
+func (e *fakeEngine) CreateDatabase(ep data.Endpoint, dbName string) error {
+	e.created = append(e.created, dbName)
+	return nil
+}
+
+func (e *fakeEngine) Restore(ep data.Endpoint, dbName string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	e.restored[dbName] = string(content)
+	return nil
+}
+
+func TestRestoreChecksBackupBeforeCreatingDatabase(t *testing.T) {
+	storage, err := data.NewStorage(data.StorageConfig{Driver: "local", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageGateway := data.NewStorageGateway(storage)
+	var dump bytes.Buffer
+	gz := gzip.NewWriter(&dump)
+	gz.Write([]byte("CREATE TABLE `t` (`id` int);\n"))
+	gz.Close()
+	for _, name := range []string{"shop-20261016030000.sql.gz", "shop-20261016040000.sql.gz.age", "shop-20261016050000.sql.gz"} {
+		if err := storage.Put(context.Background(), "shop/"+name, bytes.NewReader(dump.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := &domain.Manifest{Database: "shop", File: "shop-20261016050000.sql.gz", Compression: "brotli"}
+	if err := storageGateway.StoreManifest("shop", manifest.File, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		backup      string
+		errContains string
+	}{
+		{"shop-20261016020000.sql.gz", "not found"},
+		{"shop-20261016040000.sql.gz.age", "--identity"},
+		{"shop-20261016050000.sql.gz", "unknown compression"},
+		{"shop-20261016030000.sql.gz", ""},
+	}
+	for _, tt := range tests {
+		engine := &fakeEngine{restored: make(map[string]string)}
+		databaseGateway := data.NewDatabaseGateway(engine, "db.internal", 0, "root", "", nil, "", 0, "", "", "", 0, "", "")
+		uc := NewRestoreUseCase(databaseGateway, storageGateway, data.EncryptionConfig{})
+
+		err := uc.Execute("shop", "shop_restored", tt.backup, false)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("%s: error = %v, want %q", tt.backup, err, tt.errContains)
+			}
+			if len(engine.created) != 0 {
+				t.Errorf("%s: created %v for a backup that can't be restored", tt.backup, engine.created)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.backup, err)
+		}
+		if !reflect.DeepEqual(engine.created, []string{"shop_restored"}) || engine.restored["shop_restored"] != "CREATE TABLE `t` (`id` int);\n" {
+			t.Errorf("%s: created %v, restored %q", tt.backup, engine.created, engine.restored)
+		}
+	}
+}
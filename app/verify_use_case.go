@@ -0,0 +1,365 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// VerifyOptions selects the backups to verify
+type VerifyOptions struct {
+	// Database limits verification to one database; empty verifies every database
+	Database string
+	// All verifies every stored backup instead of the latest one per database
+	All bool
+	// RestoreTest restores each backup into a scratch database and checks its tables and row counts
+	RestoreTest bool
+	// AssertionsDir optionally holds "<database>.sql" files with queries that must return
+	// a true value against the restored database
+	AssertionsDir string
+}
+
+// VerifyUseCase checks stored backups for corruption and truncation, and
+// optionally proves they can be restored
+type VerifyUseCase struct {
+	storageGateway   *data.StorageGateway
+	databaseGateway  *data.DatabaseGateway
+	encryptionConfig data.EncryptionConfig
+}
+
+// NewVerifyUseCase creates a new VerifyUseCase instance. databaseGateway is the server
+// test restores are made on and may be nil when no restore test is run; encryptionConfig
+// supplies the key used to decrypt encrypted backups.
+func NewVerifyUseCase(storageGateway *data.StorageGateway, databaseGateway *data.DatabaseGateway,
+	encryptionConfig data.EncryptionConfig) *VerifyUseCase {
+	return &VerifyUseCase{
+		storageGateway:   storageGateway,
+		databaseGateway:  databaseGateway,
+		encryptionConfig: encryptionConfig,
+	}
+}
+
+// Execute re-reads the selected backups, validates their size and SHA-256 against the
+// manifest and decodes the whole stream. It returns an error if any backup failed.
+func (uc *VerifyUseCase) Execute(opts VerifyOptions) error {
+	backups, err := uc.selectBackups(opts)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		if opts.Database != "" {
+			return fmt.Errorf("no backups found for database %s", opts.Database)
+		}
+		return fmt.Errorf("no backups found")
+	}
+
+	if opts.RestoreTest && uc.databaseGateway == nil {
+		return fmt.Errorf("restore tests need a database connection")
+	}
+
+	var failed []string
+	for _, obj := range backups {
+		summary, err := uc.verifyBackup(obj, opts)
+		if err != nil {
+			fmt.Printf("FAIL  %s: %v\n", obj.Key, err)
+			failed = append(failed, obj.Key)
+			continue
+		}
+		fmt.Printf("OK    %s (%s)\n", obj.Key, summary)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("verification failed for %d of %d backup(s): %s", len(failed), len(backups), strings.Join(failed, ", "))
+	}
+	fmt.Printf("Verified %d backup(s)\n", len(backups))
+	return nil
+}
+
+// selectBackups returns the backups to verify: all of them, or the latest per database
+func (uc *VerifyUseCase) selectBackups(opts VerifyOptions) ([]data.ObjectInfo, error) {
+	var backups []data.ObjectInfo
+	var err error
+	if opts.Database != "" {
+		backups, err = uc.storageGateway.ListBackups(opts.Database)
+	} else {
+		backups, err = uc.storageGateway.ListAllBackups()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	if opts.All {
+		return backups, nil
+	}
+
+	// Backups are sorted newest first, so the first one of each database is its latest
+	seen := make(map[string]bool)
+	var latest []data.ObjectInfo
+	for _, obj := range backups {
+		db := path.Dir(obj.Key)
+		if !seen[db] {
+			seen[db] = true
+			latest = append(latest, obj)
+		}
+	}
+	return latest, nil
+}
+
+// verifyBackup checks one backup and returns a short summary of what was verified
+func (uc *VerifyUseCase) verifyBackup(obj data.ObjectInfo, opts VerifyOptions) (string, error) {
+	dbName, name := path.Dir(obj.Key), path.Base(obj.Key)
+	manifest, err := uc.storageGateway.LoadManifest(obj.Key)
+	if err != nil {
+		manifest = nil
+	}
+	codec, encryption, err := data.BackupFormat(name, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := uc.storageGateway.OpenBackup(dbName, name)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	stored := &meteredReader{r: reader, hash: sha256.New()}
+
+	var notes []string
+	var decoded int64 = -1
+	var scratch string
+	// Globals hold server-wide objects such as roles; restoring them would change the server.
+	// Physical backups and binary logs can't be restored into a scratch database.
+	restoreTest := opts.RestoreTest
+	if restoreTest {
+		switch {
+		case uc.databaseGateway.IsGlobals(dbName):
+			notes = append(notes, "globals are not restore-tested")
+		case uc.databaseGateway.IsPhysical(dbName):
+			notes = append(notes, "physical backups are not restore-tested")
+		case uc.databaseGateway.IsBinlog(dbName):
+			notes = append(notes, "binary logs are not restore-tested")
+		}
+		restoreTest = len(notes) == 0
+	}
+	if encryption != nil && !data.HasDecryptionKey(encryption, uc.encryptionConfig) {
+		if restoreTest {
+			return "", fmt.Errorf("can't restore: no key configured to decrypt %s backups", encryption.Name())
+		}
+		notes = append(notes, "not decrypted: no key configured")
+	} else {
+		src, err := data.DecodeStream(stored, codec, encryption, uc.encryptionConfig)
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+		plain := &meteredReader{r: src}
+		if restoreTest {
+			// The backup is read once: the restore consumes the stream that is being checksummed
+			scratch, err = uc.restoreScratch(dbName, plain)
+			if scratch != "" {
+				defer uc.dropScratch(scratch)
+			}
+			if err != nil {
+				return "", fmt.Errorf("restore test failed: %w", err)
+			}
+		} else if _, err := io.Copy(io.Discard, plain); err != nil {
+			return "", fmt.Errorf("backup is corrupted or truncated: %w", err)
+		}
+		decoded = plain.n
+	}
+	// Read anything the decoder left over so that the checksum covers the whole file
+	if _, err := io.Copy(io.Discard, stored); err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if err := checkManifest(manifest, stored, decoded); err != nil {
+		return "", err
+	}
+	if manifest == nil {
+		notes = append(notes, "no manifest: checksum not checked")
+	} else {
+		notes = append([]string{"sha256 ok"}, notes...)
+	}
+	if decoded >= 0 {
+		notes = append(notes, domain.FormatSize(decoded)+" decoded")
+	}
+	if scratch != "" {
+		restored, err := uc.checkRestore(dbName, scratch, manifest, opts.AssertionsDir)
+		if err != nil {
+			return "", fmt.Errorf("restore test failed: %w", err)
+		}
+		notes = append(notes, "restore test: "+restored)
+	}
+	return strings.Join(notes, ", "), nil
+}
+
+// scratchDatabaseName returns a unique name for the test restore of dbName,
+// within the name limits of MySQL (64 characters) and PostgreSQL (63 bytes)
+func scratchDatabaseName(dbName string) string {
+	suffix := "_" + time.Now().Format(domain.BackupTimestampFormat)
+	prefix := "verify_" + dbName
+	if len(prefix)+len(suffix) > 63 {
+		prefix = prefix[:63-len(suffix)]
+	}
+	return prefix + suffix
+}
+
+// restoreScratch restores the decoded dump from r into a new scratch database and returns its
+// name; the name is also returned on failure if the database was created and must be dropped
+func (uc *VerifyUseCase) restoreScratch(dbName string, r io.Reader) (string, error) {
+	scratch := scratchDatabaseName(dbName)
+	empty, err := uc.databaseGateway.IsDatabaseEmpty(scratch)
+	if err != nil {
+		return "", err
+	}
+	if !empty {
+		return "", fmt.Errorf("scratch database %s already exists", scratch)
+	}
+	if err := uc.databaseGateway.CreateDatabase(scratch); err != nil {
+		return "", err
+	}
+	return scratch, uc.databaseGateway.RestoreDatabase(scratch, r)
+}
+
+// dropScratch drops a scratch database after a restore test
+func (uc *VerifyUseCase) dropScratch(scratch string) {
+	if err := uc.databaseGateway.DropDatabase(scratch); err != nil {
+		fmt.Printf("Warning: failed to drop scratch database %s: %v\n", scratch, err)
+	}
+}
+
+// checkRestore compares the restored scratch database with the tables and row counts
+// recorded at dump time and runs the database's assertions
+func (uc *VerifyUseCase) checkRestore(dbName string, scratch string, manifest *domain.Manifest, assertionsDir string) (string, error) {
+	counts, err := uc.databaseGateway.TableRowCounts(scratch)
+	if err != nil {
+		return "", err
+	}
+	if len(counts) == 0 {
+		return "", fmt.Errorf("no tables were restored")
+	}
+
+	var total int64
+	for _, rows := range counts {
+		total += rows
+	}
+	if manifest != nil && manifest.Tables != nil {
+		var problems []string
+		for _, table := range sortedKeys(manifest.Tables) {
+			rows, ok := counts[table]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("table %s is missing", table))
+			} else if rows != manifest.Tables[table] {
+				problems = append(problems, fmt.Sprintf("table %s has %d rows, %d were dumped", table, rows, manifest.Tables[table]))
+			}
+		}
+		if len(problems) > 0 {
+			return "", fmt.Errorf("%s", strings.Join(problems, "; "))
+		}
+	}
+	summary := fmt.Sprintf("%d tables, %d rows", len(counts), total)
+
+	assertions, err := loadAssertions(assertionsDir, dbName)
+	if err != nil {
+		return "", err
+	}
+	for _, query := range assertions {
+		value, ok, err := uc.databaseGateway.QueryValue(scratch, query)
+		if err != nil {
+			return "", fmt.Errorf("assertion %q failed: %w", query, err)
+		}
+		if !ok || value == "" || value == "0" {
+			return "", fmt.Errorf("assertion %q is not true", query)
+		}
+	}
+	if len(assertions) > 0 {
+		summary += fmt.Sprintf(", %d assertions passed", len(assertions))
+	}
+	return summary, nil
+}
+
+// loadAssertions reads the queries in "<dir>/<database>.sql". Queries are separated by a
+// semicolon at the end of a line; lines starting with "--" are comments.
+func loadAssertions(dir string, dbName string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(filepath.Join(dir, dbName+".sql"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assertions: %w", err)
+	}
+
+	var queries []string
+	var current []string
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, trimmed)
+		if strings.HasSuffix(trimmed, ";") {
+			queries = append(queries, strings.TrimSuffix(strings.Join(current, " "), ";"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		queries = append(queries, strings.Join(current, " "))
+	}
+	return queries, nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkManifest compares what was read with the sizes and checksum recorded at backup time
+func checkManifest(manifest *domain.Manifest, stored *meteredReader, decoded int64) error {
+	if manifest == nil {
+		return nil
+	}
+	if stored.n != manifest.Size {
+		return fmt.Errorf("size mismatch: manifest records %d bytes, read %d", manifest.Size, stored.n)
+	}
+	if sum := hex.EncodeToString(stored.hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("checksum mismatch: manifest records sha256 %s, got %s", manifest.SHA256, sum)
+	}
+	if decoded >= 0 && manifest.UncompressedSize > 0 && decoded != manifest.UncompressedSize {
+		return fmt.Errorf("dump size mismatch: manifest records %d bytes, decoded %d", manifest.UncompressedSize, decoded)
+	}
+	return nil
+}
+
+// meteredReader counts, and optionally hashes, the bytes read through it
+type meteredReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if m.hash != nil {
+		m.hash.Write(p[:n])
+	}
+	m.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/magicstack-llp/db-backup-go/domain"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Codec compresses and decompresses backup streams
+type Codec interface {
+	// Name returns the codec name used in configuration (e.g. "zstd")
+	Name() string
+	// Extension returns the file extension appended to backups (e.g. ".zst")
+	Extension() string
+	// NewWriter wraps w with a compressor; level 0 selects the codec default
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+	// NewReader wraps r with a decompressor
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = make(map[string]Codec)
+
+// RegisterCodec registers a compression codec under its name
+func RegisterCodec(codec Codec) {
+	codecs[codec.Name()] = codec
+}
+
+func init() {
+	RegisterCodec(noneCodec{})
+	RegisterCodec(gzipCodec{})
+	RegisterCodec(zstdCodec{})
+	RegisterCodec(xzCodec{})
+	RegisterCodec(lz4Codec{})
+}
+
+// CodecNames returns the names of all registered codecs
+func CodecNames() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetCodec returns the codec registered under name
+func GetCodec(name string) (Codec, error) {
+	codec, ok := codecs[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression '%s' (available: %s)", name, strings.Join(CodecNames(), ", "))
+	}
+	return codec, nil
+}
+
+// CodecForFile returns the codec matching a backup file's extension
+// (the "none" codec for plain .sql files); encryption extensions are ignored
+func CodecForFile(name string) Codec {
+	name = trimEncryptionExtension(name)
+	for _, codec := range codecs {
+		if ext := codec.Extension(); ext != "" && strings.HasSuffix(name, ext) {
+			return codec
+		}
+	}
+	return noneCodec{}
+}
+
+// isBackupFile reports whether a file name is a (possibly compressed and encrypted) SQL
+// dump, physical backup or binary log
+func isBackupFile(name string) bool {
+	name = trimEncryptionExtension(name)
+	name = strings.TrimSuffix(name, CodecForFile(name).Extension())
+	return strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, PhysicalExtension) ||
+		strings.HasSuffix(name, BinlogExtension)
+}
+
+// BackupTypeForFile returns the type of a backup from its file name
+func BackupTypeForFile(name string) string {
+	name = trimEncryptionExtension(name)
+	name = strings.TrimSuffix(name, CodecForFile(name).Extension())
+	switch {
+	case strings.HasSuffix(name, PhysicalExtension):
+		return domain.BackupTypePhysical
+	case strings.HasSuffix(name, BinlogExtension):
+		return domain.BackupTypeBinlog
+	}
+	return domain.BackupTypeLogical
+}
+
+// noneCodec stores backups uncompressed
+type noneCodec struct{}
+
+func (noneCodec) Name() string      { return "none" }
+func (noneCodec) Extension() string { return "" }
+
+func (noneCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (noneCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// gzipCodec compresses with gzip (levels 1-9)
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string      { return "gzip" }
+func (gzipCodec) Extension() string { return ".gz" }
+
+func (gzipCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// zstdCodec compresses with Zstandard (levels 1-22, mapped to the encoder's speed presets)
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string      { return "zstd" }
+func (zstdCodec) Extension() string { return ".zst" }
+
+func (zstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	var opts []zstd.EOption
+	if level != 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	return zstd.NewWriter(w, opts...)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// xzCodec compresses with xz/LZMA2 (levels 1-9 select the dictionary size like xz presets; 0 keeps the default)
+type xzCodec struct{}
+
+func (xzCodec) Name() string      { return "xz" }
+func (xzCodec) Extension() string { return ".xz" }
+
+// xzDictCaps maps xz preset levels to dictionary sizes
+var xzDictCaps = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func (xzCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	cfg := xz.WriterConfig{}
+	if level > 0 && level < len(xzDictCaps) {
+		cfg.DictCap = xzDictCaps[level]
+	}
+	return cfg.NewWriter(w)
+}
+
+func (xzCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	reader, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(reader), nil
+}
+
+// lz4Codec compresses with LZ4 frames (levels 1-9 trade speed for ratio; 0 is the fast default)
+type lz4Codec struct{}
+
+func (lz4Codec) Name() string      { return "lz4" }
+func (lz4Codec) Extension() string { return ".lz4" }
+
+func (lz4Codec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level < 0 || level > 9 {
+		return nil, fmt.Errorf("invalid lz4 compression level %d (use 1-9)", level)
+	}
+	writer := lz4.NewWriter(w)
+	if level > 0 {
+		if err := writer.Apply(lz4.CompressionLevelOption(lz4.CompressionLevel(1 << (8 + level)))); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+func (lz4Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	input := []byte(strings.Repeat("INSERT INTO `orders` VALUES (1,'pending',42.50);\n", 2000))
+	levels := map[string][]int{
+		"none": {0},
+		"gzip": {0, 1, 6, 9},
+		"zstd": {0, 1, 3, 19, 22},
+		"xz":   {0, 1, 6, 9},
+		"lz4":  {0, 1, 5, 9},
+	}
+
+	for _, name := range CodecNames() {
+		codec, err := GetCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, level := range levels[name] {
+			var compressed bytes.Buffer
+			w, err := codec.NewWriter(&compressed, level)
+			if err != nil {
+				t.Fatalf("%s level %d: NewWriter: %v", name, level, err)
+			}
+			if _, err := w.Write(input); err != nil {
+				t.Fatalf("%s level %d: Write: %v", name, level, err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("%s level %d: Close: %v", name, level, err)
+			}
+
+			r, err := codec.NewReader(&compressed)
+			if err != nil {
+				t.Fatalf("%s level %d: NewReader: %v", name, level, err)
+			}
+			output, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("%s level %d: read: %v", name, level, err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Errorf("%s level %d: round trip returned %d bytes, want %d", name, level, len(output), len(input))
+			}
+		}
+	}
+
+	// lz4 levels above 9 are rejected rather than silently ignored
+	if _, err := (lz4Codec{}).NewWriter(io.Discard, 12); err == nil {
+		t.Error("lz4 level 12: expected an error")
+	}
+}
+
+func TestCodecForFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  string
+		backup bool
+	}{
+		{"shop-20261016030000.sql", "none", true},
+		{"shop-20261016030000.sql.gz", "gzip", true},
+		{"shop-20261016030000.sql.zst", "zstd", true},
+		{"shop-20261016030000.sql.xz", "xz", true},
+		{"shop-20261016030000.sql.lz4", "lz4", true},
+		{"shop-20261016030000.tar.gz", "gzip", false},
+		{"notes.txt", "none", false},
+	}
+	for _, tt := range tests {
+		if got := CodecForFile(tt.name).Name(); got != tt.codec {
+			t.Errorf("CodecForFile(%s) = %s, want %s", tt.name, got, tt.codec)
+		}
+		if got := isBackupFile(tt.name); got != tt.backup {
+			t.Errorf("isBackupFile(%s) = %v, want %v", tt.name, got, tt.backup)
+		}
+	}
+	if _, err := GetCodec("brotli"); err == nil {
+		t.Error("GetCodec(brotli): expected an error")
+	}
+}
@@ -5,27 +5,70 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
 )
 
 // Connection represents a database connection configuration
 type Connection struct {
-	Host            string   `json:"host"`
-	Port            int      `json:"port"`
-	User            string   `json:"user"`
-	Password        string   `json:"password"`
-	MysqldumpPath   string   `json:"mysqldump_path,omitempty"`
-	ExcludedDBs     []string `json:"excluded_databases,omitempty"`
-	StorageDriver   string   `json:"storage_driver,omitempty"`
-	Path            string   `json:"path,omitempty"`
-	S3Bucket        string   `json:"s3_bucket,omitempty"`
-	SSHHost         string   `json:"ssh_host,omitempty"`
-	SSHPort         int      `json:"ssh_port,omitempty"`
-	SSHUser         string   `json:"ssh_user,omitempty"`
-	SSHKeyPath      string   `json:"ssh_key_path,omitempty"`
-	BastionHost     string   `json:"bastion_host,omitempty"`
-	BastionPort     int      `json:"bastion_port,omitempty"`
-	BastionUser     string   `json:"bastion_user,omitempty"`
-	BastionKeyPath  string   `json:"bastion_key_path,omitempty"`
+	Host                  string                  `json:"host"`
+	Port                  int                     `json:"port"`
+	User                  string                  `json:"user"`
+	Password              string                  `json:"password"`
+	Engine                string                  `json:"engine,omitempty"`
+	MysqldumpPath         string                  `json:"mysqldump_path,omitempty"`
+	MysqlPath             string                  `json:"mysql_path,omitempty"`
+	DumpMethod            string                  `json:"dump_method,omitempty"`
+	BackupType            string                  `json:"backup_type,omitempty"`
+	XtrabackupPath        string                  `json:"xtrabackup_path,omitempty"`
+	BinlogArchive         bool                    `json:"binlog_archive,omitempty"`
+	MysqlbinlogPath       string                  `json:"mysqlbinlog_path,omitempty"`
+	PgDumpPath            string                  `json:"pg_dump_path,omitempty"`
+	PsqlPath              string                  `json:"psql_path,omitempty"`
+	PgGlobals             bool                    `json:"pg_globals,omitempty"`
+	PgIncludePostgresDB   bool                    `json:"pg_include_postgres_db,omitempty"`
+	MariaDBUsers          bool                    `json:"mariadb_users,omitempty"`
+	ExcludedDBs           []string                `json:"excluded_databases,omitempty"`
+	Parallel              int                     `json:"parallel,omitempty"`
+	Retention             *domain.RetentionPolicy `json:"retention,omitempty"`
+	Compression           string                  `json:"compression,omitempty"`
+	CompressionLevel      int                     `json:"compression_level,omitempty"`
+	Encryption            string                  `json:"encryption,omitempty"`
+	Recipients            []string                `json:"encryption_recipients,omitempty"`
+	IdentityFile          string                  `json:"encryption_identity,omitempty"`
+	KeyFile               string                  `json:"encryption_key_file,omitempty"`
+	StorageDriver         string                  `json:"storage_driver,omitempty"`
+	Path                  string                  `json:"path,omitempty"`
+	S3Bucket              string                  `json:"s3_bucket,omitempty"`
+	S3Endpoint            string                  `json:"s3_endpoint,omitempty"`
+	S3Region              string                  `json:"s3_region,omitempty"`
+	S3ForcePathStyle      bool                    `json:"s3_force_path_style,omitempty"`
+	S3SSE                 string                  `json:"s3_server_side_encryption,omitempty"`
+	S3KMSKeyID            string                  `json:"s3_kms_key_id,omitempty"`
+	S3StorageClass        string                  `json:"s3_storage_class,omitempty"`
+	S3ChecksumAlgorithm   string                  `json:"s3_checksum_algorithm,omitempty"`
+	S3Tags                map[string]string       `json:"s3_tags,omitempty"`
+	S3Metadata            map[string]string       `json:"s3_metadata,omitempty"`
+	S3ObjectLockMode      string                  `json:"s3_object_lock_mode,omitempty"`
+	S3ObjectLockRetention string                  `json:"s3_object_lock_retention,omitempty"`
+	S3LegalHold           bool                    `json:"s3_legal_hold,omitempty"`
+	ImmutableMinAge       string                  `json:"immutable_min_age,omitempty"`
+	SSHHost               string                  `json:"ssh_host,omitempty"`
+	SSHPort               int                     `json:"ssh_port,omitempty"`
+	SSHUser               string                  `json:"ssh_user,omitempty"`
+	SSHKeyPath            string                  `json:"ssh_key_path,omitempty"`
+	BastionHost           string                  `json:"bastion_host,omitempty"`
+	BastionPort           int                     `json:"bastion_port,omitempty"`
+	BastionUser           string                  `json:"bastion_user,omitempty"`
+	BastionKeyPath        string                  `json:"bastion_key_path,omitempty"`
+	SFTPHost              string                  `json:"sftp_host,omitempty"`
+	SFTPPort              int                     `json:"sftp_port,omitempty"`
+	SFTPUser              string                  `json:"sftp_user,omitempty"`
+	SFTPKeyPath           string                  `json:"sftp_key_path,omitempty"`
+	SFTPBastionHost       string                  `json:"sftp_bastion_host,omitempty"`
+	SFTPBastionPort       int                     `json:"sftp_bastion_port,omitempty"`
+	SFTPBastionUser       string                  `json:"sftp_bastion_user,omitempty"`
+	SFTPBastionKeyPath    string                  `json:"sftp_bastion_key_path,omitempty"`
 }
 
 // ConnectionManager manages database connections stored in JSON format
@@ -1,42 +1,50 @@
 package data
 
 import (
-	"database/sql"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/magicstack-llp/db-backup-go/domain"
 )
 
-// DatabaseGateway handles database operations
+// DatabaseGateway handles database operations. Server-specific work is done by its
+// Engine; the gateway manages the SSH tunnel and streams dumps through compression.
 type DatabaseGateway struct {
+	engine          Engine
 	host            string
 	port            int
 	user            string
 	password        string
-	mysqldumpPath   string
 	excludedDBs     map[string]bool
 	sshTunnel       *SSHTunnel
 	effectiveHost   string
 	effectivePort   int
+	// tunnelMu serializes starting and stopping the shared SSH tunnel between parallel dumps
+	tunnelMu        sync.Mutex
 }
 
-// NewDatabaseGateway creates a new DatabaseGateway instance
-func NewDatabaseGateway(host string, port int, user string, password string,
-	mysqldumpPath string, excludedDBs []string,
+// NewDatabaseGateway creates a new DatabaseGateway instance; a port of 0 selects the
+// engine's default port
+func NewDatabaseGateway(engine Engine, host string, port int, user string, password string,
+	excludedDBs []string,
 	sshHost string, sshPort int, sshUser string, sshKeyPath string,
 	bastionHost string, bastionPort int, bastionUser string, bastionKeyPath string) *DatabaseGateway {
 	
+	if port == 0 {
+		port = engine.DefaultPort()
+	}
+	
 	// Default excluded databases
-	systemExcluded := map[string]bool{
-		"information_schema": true,
-		"performance_schema": true,
-		"mysql":              true,
-		"sys":                true,
+	systemExcluded := make(map[string]bool)
+	for _, db := range engine.SystemDatabases() {
+		systemExcluded[db] = true
 	}
 	
 	// Add user-specified exclusions
@@ -46,20 +54,12 @@ func NewDatabaseGateway(host string, port int, user string, password string,
 		}
 	}
 	
-	// Resolve mysqldump path
-	if mysqldumpPath == "" {
-		mysqldumpPath = os.Getenv("MYSQLDUMP_PATH")
-	}
-	if mysqldumpPath == "" {
-		mysqldumpPath = "mysqldump"
-	}
-	
 	gateway := &DatabaseGateway{
+		engine:        engine,
 		host:          host,
 		port:          port,
 		user:          user,
 		password:      password,
-		mysqldumpPath: mysqldumpPath,
 		excludedDBs:   systemExcluded,
 		effectiveHost: host,
 		effectivePort: port,
@@ -77,8 +77,12 @@ func NewDatabaseGateway(host string, port int, user string, password string,
 	return gateway
 }
 
-// ensureSSHTunnel ensures SSH tunnel is established if configured
+// ensureSSHTunnel ensures SSH tunnel is established if configured. It is safe for
+// concurrent use; all callers share the same tunnel, and the effective endpoint only
+// changes while the lock is held.
 func (dg *DatabaseGateway) ensureSSHTunnel() error {
+	dg.tunnelMu.Lock()
+	defer dg.tunnelMu.Unlock()
 	if dg.sshTunnel != nil {
 		if dg.effectiveHost == dg.host && dg.effectivePort == dg.port {
 			// Tunnel not started yet
@@ -89,15 +93,14 @@ func (dg *DatabaseGateway) ensureSSHTunnel() error {
 			dg.effectiveHost = "127.0.0.1"
 			dg.effectivePort = localPort
 		}
-	} else {
-		dg.effectiveHost = dg.host
-		dg.effectivePort = dg.port
 	}
 	return nil
 }
 
 // cleanupSSHTunnel cleans up SSH tunnel if it exists
 func (dg *DatabaseGateway) cleanupSSHTunnel() {
+	dg.tunnelMu.Lock()
+	defer dg.tunnelMu.Unlock()
 	if dg.sshTunnel != nil {
 		dg.sshTunnel.Stop()
 		dg.effectiveHost = dg.host
@@ -105,104 +108,507 @@ func (dg *DatabaseGateway) cleanupSSHTunnel() {
 	}
 }
 
-// ListDatabases lists all databases excluding system databases
-func (dg *DatabaseGateway) ListDatabases() ([]*domain.Database, error) {
+// endpoint starts the SSH tunnel if needed and returns the server to connect to
+func (dg *DatabaseGateway) endpoint() (Endpoint, error) {
 	if err := dg.ensureSSHTunnel(); err != nil {
-		return nil, err
+		return Endpoint{}, err
+	}
+	return Endpoint{Host: dg.effectiveHost, Port: dg.effectivePort, User: dg.user, Password: dg.password}, nil
+}
+
+// Engine returns the name of the database engine
+func (dg *DatabaseGateway) Engine() string {
+	return dg.engine.Name()
+}
+
+// globalsEngine returns the engine if dbName is the pseudo-database of its globals
+func (dg *DatabaseGateway) globalsEngine(dbName string) (GlobalsEngine, bool) {
+	engine, ok := dg.engine.(GlobalsEngine)
+	return engine, ok && dbName == GlobalsDatabase
+}
+
+// IsGlobals reports whether dbName stands for the server-wide objects backed up by
+// engines such as Postgres rather than for a database
+func (dg *DatabaseGateway) IsGlobals(dbName string) bool {
+	_, ok := dg.globalsEngine(dbName)
+	return ok
+}
+
+// physicalEngine returns the engine if it takes physical backups
+func (dg *DatabaseGateway) physicalEngine() (PhysicalEngine, error) {
+	engine, ok := dg.engine.(PhysicalEngine)
+	if !ok {
+		return nil, fmt.Errorf("physical backups are not supported for %s connections", dg.engine.Name())
+	}
+	return engine, nil
+}
+
+// IsPhysical reports whether dbName stands for the physical backups of the server
+func (dg *DatabaseGateway) IsPhysical(dbName string) bool {
+	return dbName == PhysicalDatabase
+}
+
+// CheckPhysical returns an error if the connection's engine can't take physical backups
+func (dg *DatabaseGateway) CheckPhysical() error {
+	_, err := dg.physicalEngine()
+	return err
+}
+
+// hostEndpoint is the server as seen from the database host, where physical backup
+// tools run
+func (dg *DatabaseGateway) hostEndpoint() Endpoint {
+	return Endpoint{Host: dg.host, Port: dg.port, User: dg.user, Password: dg.password}
+}
+
+// runOnHost runs a command on the database host: over SSH when the connection uses a
+// tunnel, locally otherwise
+func (dg *DatabaseGateway) runOnHost(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if dg.sshTunnel == nil {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
 	}
 	
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", dg.user, dg.password, dg.effectiveHost, dg.effectivePort)
-	db, err := sql.Open("mysql", dsn)
+	dg.tunnelMu.Lock()
+	err := dg.sshTunnel.Connect()
+	dg.tunnelMu.Unlock()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
+		return fmt.Errorf("failed to connect to SSH host: %w", err)
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return dg.sshTunnel.Run(strings.Join(quoted, " "), stdin, stdout, stderr)
+}
+
+// physicalTools returns the physical backup tool and extractor for the server
+func (dg *DatabaseGateway) physicalTools(engine PhysicalEngine) (string, string, error) {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return "", "", err
+	}
+	flavor, version, err := dg.engine.ServerVersion(ep)
+	if err != nil {
+		return "", "", err
+	}
+	backup, extract := engine.PhysicalTools(flavor, version)
+	return backup, extract, nil
+}
+
+// backupPhysical streams a physical backup of the server to stdout
+func (dg *DatabaseGateway) backupPhysical(stdout io.Writer, stderr io.Writer) error {
+	engine, err := dg.physicalEngine()
+	if err != nil {
+		return err
+	}
+	backup, _, err := dg.physicalTools(engine)
+	if err != nil {
+		return err
 	}
-	defer db.Close()
 	
-	rows, err := db.Query("SHOW DATABASES")
+	args := append([]string{backup}, engine.PhysicalBackupFlags()...)
+	args = append(args, engine.PhysicalConnectionArgs(dg.hostEndpoint())...)
+	if err := dg.runOnHost(args, nil, stdout, stderr); err != nil {
+		return fmt.Errorf("%s failed: %w", filepath.Base(backup), err)
+	}
+	return nil
+}
+
+// PhysicalDumperInfo describes the physical backup tool. The version is left empty when
+// the tool can't be run.
+func (dg *DatabaseGateway) PhysicalDumperInfo() domain.DumperInfo {
+	engine, err := dg.physicalEngine()
+	if err != nil {
+		return domain.DumperInfo{}
+	}
+	backup, _, err := dg.physicalTools(engine)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query databases: %w", err)
+		return domain.DumperInfo{}
 	}
-	defer rows.Close()
 	
-	var databases []*domain.Database
-	for rows.Next() {
-		var dbName string
-		if err := rows.Scan(&dbName); err != nil {
-			continue
+	// The version is printed to stderr, possibly after lines about the server options
+	var out bytes.Buffer
+	version := ""
+	if dg.runOnHost([]string{backup, "--version"}, nil, &out, &out) == nil {
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.Contains(line, "version") {
+				version = strings.TrimSpace(line)
+				break
+			}
 		}
-		
+	}
+	return domain.DumperInfo{Tool: filepath.Base(backup), Version: version, Flags: engine.PhysicalBackupFlags()}
+}
+
+// RestorePhysical extracts a physical backup read from r into targetDir on the database
+// host and prepares it. flavor and version describe the server the backup was taken
+// from. With copyBack the prepared files are then copied into the data directory of the
+// local server, which must be stopped and empty.
+func (dg *DatabaseGateway) RestorePhysical(r io.Reader, targetDir string, flavor string, version string, copyBack bool) error {
+	engine, err := dg.physicalEngine()
+	if err != nil {
+		return err
+	}
+	backup, extract := engine.PhysicalTools(flavor, version)
+	
+	empty := []string{"sh", "-c", `test ! -e "$1" || test -z "$(ls -A "$1")"`, "sh", targetDir}
+	if err := dg.runOnHost(empty, nil, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("target directory %s must be empty or not exist", targetDir)
+	}
+	if err := dg.runOnHost([]string{"mkdir", "-p", targetDir}, nil, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("failed to create %s: %w", targetDir, err)
+	}
+	
+	fmt.Printf("Extracting backup into %s...\n", targetDir)
+	if err := dg.runOnHost([]string{extract, "-x", "-C", targetDir}, r, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("%s failed: %w", filepath.Base(extract), err)
+	}
+	
+	fmt.Println("Preparing backup...")
+	if err := dg.runOnHost([]string{backup, "--prepare", "--target-dir=" + targetDir}, nil, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("%s --prepare failed: %w", filepath.Base(backup), err)
+	}
+	
+	if copyBack {
+		fmt.Println("Copying files into the data directory...")
+		if err := dg.runOnHost([]string{backup, "--copy-back", "--target-dir=" + targetDir}, nil, os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("%s --copy-back failed: %w", filepath.Base(backup), err)
+		}
+	}
+	return nil
+}
+
+// binlogEngine returns the engine if it archives binary logs
+func (dg *DatabaseGateway) binlogEngine() (BinlogEngine, error) {
+	engine, ok := dg.engine.(BinlogEngine)
+	if !ok {
+		return nil, fmt.Errorf("binary log archiving is not supported for %s connections", dg.engine.Name())
+	}
+	return engine, nil
+}
+
+// CheckBinlogs returns an error if the connection's engine can't archive binary logs
+func (dg *DatabaseGateway) CheckBinlogs() error {
+	_, err := dg.binlogEngine()
+	return err
+}
+
+// IsBinlog reports whether dbName stands for the archived binary logs of the server
+func (dg *DatabaseGateway) IsBinlog(dbName string) bool {
+	return dbName == BinlogDatabase
+}
+
+// ListBinlogs returns the binary logs on the server, oldest first; the last one is still
+// being written
+func (dg *DatabaseGateway) ListBinlogs() ([]string, error) {
+	engine, err := dg.binlogEngine()
+	if err != nil {
+		return nil, err
+	}
+	ep, err := dg.endpoint()
+	if err != nil {
+		return nil, err
+	}
+	return engine.ListBinlogs(ep)
+}
+
+// FlushBinlogs closes the binary log being written, so that it can be archived
+func (dg *DatabaseGateway) FlushBinlogs() error {
+	engine, err := dg.binlogEngine()
+	if err != nil {
+		return err
+	}
+	ep, err := dg.endpoint()
+	if err != nil {
+		return err
+	}
+	return engine.FlushBinlogs(ep)
+}
+
+// BinlogDumperInfo describes the tool binary logs are fetched with. The version is left
+// empty when the tool can't be run.
+func (dg *DatabaseGateway) BinlogDumperInfo() domain.DumperInfo {
+	engine, err := dg.binlogEngine()
+	if err != nil {
+		return domain.DumperInfo{}
+	}
+	return engine.BinlogDumperInfo()
+}
+
+// ReplayBinlogs applies the events of dbName in the binary log files to targetDB, from
+// position start of the first file up to and including stop
+func (dg *DatabaseGateway) ReplayBinlogs(dbName string, targetDB string, files []string, start int64, stop time.Time) error {
+	engine, err := dg.binlogEngine()
+	if err != nil {
+		return err
+	}
+	ep, err := dg.endpoint()
+	if err != nil {
+		return err
+	}
+	return engine.ReplayBinlogs(ep, dbName, targetDB, files, start, stop)
+}
+
+// PhysicalTool returns the physical backup tool used to restore backups of a server of
+// the given flavor and version
+func (dg *DatabaseGateway) PhysicalTool(flavor string, version string) string {
+	engine, err := dg.physicalEngine()
+	if err != nil {
+		return ""
+	}
+	backup, _ := engine.PhysicalTools(flavor, version)
+	return backup
+}
+
+// ListDatabases lists all databases excluding system databases, followed by
+// GlobalsDatabase when the engine backs up globals
+func (dg *DatabaseGateway) ListDatabases() ([]*domain.Database, error) {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return nil, err
+	}
+	
+	names, err := dg.engine.ListDatabases(ep)
+	if err != nil {
+		return nil, err
+	}
+	
+	var databases []*domain.Database
+	for _, dbName := range names {
 		if !dg.excludedDBs[dbName] {
 			databases = append(databases, domain.NewDatabase(dbName))
 		}
 	}
+	if engine, ok := dg.engine.(GlobalsEngine); ok && engine.DumpsGlobals() {
+		databases = append(databases, domain.NewDatabase(GlobalsDatabase))
+	}
 	
 	return databases, nil
 }
 
-// BackupDatabase backs up a database using mysqldump
-func (dg *DatabaseGateway) BackupDatabase(dbName string, backupPath string) error {
-	if err := dg.ensureSSHTunnel(); err != nil {
-		return err
+// BackupDatabase dumps a database with the engine's dump tool, streaming the dump to w
+// through the codec's compressor. It returns the size and table row counts of the dump.
+func (dg *DatabaseGateway) BackupDatabase(dbName string, w io.Writer, codec Codec, level int) (*DumpStats, error) {
+	// Physical backups are binary; only their size is recorded
+	return dg.backup(dbName, w, codec, level, dg.IsPhysical(dbName), func(ep Endpoint, stdout io.Writer, stderr io.Writer) error {
+		if dg.IsPhysical(dbName) {
+			return dg.backupPhysical(stdout, stderr)
+		}
+		if engine, ok := dg.globalsEngine(dbName); ok {
+			return engine.DumpGlobals(ep, stdout, stderr)
+		}
+		return dg.engine.Dump(ep, dbName, stdout, stderr)
+	})
+}
+
+// BackupBinlog fetches the binary log name from the server and writes it compressed to w.
+// The stats name the binary log and the times of its first and last event.
+func (dg *DatabaseGateway) BackupBinlog(name string, w io.Writer, codec Codec, level int) (*DumpStats, error) {
+	engine, err := dg.binlogEngine()
+	if err != nil {
+		return nil, err
 	}
-	
-	// Resolve mysqldump absolute path
-	mysqldump := dg.mysqldumpPath
-	if !filepath.IsAbs(mysqldump) {
-		resolved, err := exec.LookPath(mysqldump)
+	var first, last time.Time
+	stats, err := dg.backup(name, w, codec, level, true, func(ep Endpoint, stdout io.Writer, stderr io.Writer) error {
+		// mysqlbinlog --raw only writes to files
+		dir, err := os.MkdirTemp("", "db-backup-binlog-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		
+		path, err := engine.FetchBinlog(ep, name, dir, stderr)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("mysqldump not found. Set MYSQLDUMP_PATH in .env or ensure '%s' is in PATH", mysqldump)
+			return fmt.Errorf("failed to read binary log %s: %w", name, err)
 		}
-		mysqldump = resolved
+		defer file.Close()
+		size, err := io.Copy(stdout, file)
+		if err != nil {
+			return fmt.Errorf("failed to read binary log %s: %w", name, err)
+		}
+		first, last, err = binlogEventTimes(file, size)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.Binlog = &domain.BinlogPosition{File: name}
+	if !first.IsZero() {
+		stats.Binlog.FirstEvent, stats.Binlog.LastEvent = &first, &last
+	}
+	return stats, nil
+}
+
+// backup runs dump and compresses what it writes into w, collecting statistics unless
+// sizeOnly is set; label prefixes the messages of the dump tool
+func (dg *DatabaseGateway) backup(label string, w io.Writer, codec Codec, level int, sizeOnly bool,
+	dump func(ep Endpoint, stdout io.Writer, stderr io.Writer) error) (*DumpStats, error) {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return nil, err
 	}
 	
-	// Ensure backup directory exists
-	backupDir := filepath.Dir(backupPath)
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+	compressor, err := codec.NewWriter(w, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s compression: %w", codec.Name(), err)
 	}
 	
-	// Build mysqldump command
-	cmd := exec.Command(mysqldump,
-		fmt.Sprintf("--host=%s", dg.effectiveHost),
-		fmt.Sprintf("--port=%d", dg.effectivePort),
-		fmt.Sprintf("--user=%s", dg.user),
-		fmt.Sprintf("--password=%s", dg.password),
-		"--single-transaction",
-		"--quick",
-		"--skip-lock-tables",
-		dbName,
-	)
+	stats := newDumpStatsWriter()
+	stats.sizeOnly = sizeOnly
+	stdout := io.MultiWriter(stats, compressor)
+	// Prefix the dump tool's messages with the database so parallel dumps stay readable
+	stderr := NewLinePrefixWriter(os.Stderr, "["+label+"] ")
+	defer stderr.Flush()
 	
-	// Create output file
-	outFile, err := os.Create(backupPath)
-	if err != nil {
-		return fmt.Errorf("failed to create backup file: %w", err)
+	// Run the dump
+	if err := dump(ep, stdout, stderr); err != nil {
+		compressor.Close()
+		return nil, err
 	}
-	defer outFile.Close()
 	
-	cmd.Stdout = outFile
-	cmd.Stderr = os.Stderr
+	// Verify the dump is non-empty
+	if stats.stats.Size == 0 {
+		compressor.Close()
+		return nil, fmt.Errorf("backup is empty. Check the dump tool's permissions and options")
+	}
 	
-	// Run mysqldump
-	if err := cmd.Run(); err != nil {
-		// Clean up empty file
-		if info, statErr := os.Stat(backupPath); statErr == nil && info.Size() == 0 {
-			os.Remove(backupPath)
-		}
-		return fmt.Errorf("mysqldump failed: %w", err)
+	if err := compressor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress backup: %w", err)
 	}
 	
-	// Verify file exists and is non-empty
-	info, err := os.Stat(backupPath)
+	return &stats.stats, nil
+}
+
+// Source returns the configured database server as host:port
+func (dg *DatabaseGateway) Source() string {
+	return fmt.Sprintf("%s:%d", dg.host, dg.port)
+}
+
+// ServerVersion returns the flavor of the database server, e.g. "mysql" or "mariadb",
+// and the version it reports
+func (dg *DatabaseGateway) ServerVersion() (flavor string, version string, err error) {
+	ep, err := dg.endpoint()
 	if err != nil {
-		return fmt.Errorf("backup file not found: %w", err)
+		return "", "", err
 	}
-	if info.Size() == 0 {
-		os.Remove(backupPath)
-		return fmt.Errorf("backup file is empty. Check mysqldump permissions and options")
+	return dg.engine.ServerVersion(ep)
+}
+
+// DumperInfo describes the dump tool and flags used for backups. The version
+// is left empty when the tool can't be run.
+func (dg *DatabaseGateway) DumperInfo() domain.DumperInfo {
+	return dg.engine.DumperInfo()
+}
+
+// IsDatabaseEmpty reports whether a database has no tables (or does not exist).
+// Globals can always be restored.
+func (dg *DatabaseGateway) IsDatabaseEmpty(dbName string) (bool, error) {
+	if dg.IsGlobals(dbName) {
+		return true, nil
+	}
+	ep, err := dg.endpoint()
+	if err != nil {
+		return false, err
+	}
+	return dg.engine.IsDatabaseEmpty(ep, dbName)
+}
+
+// CreateDatabase creates a database if it does not exist yet
+func (dg *DatabaseGateway) CreateDatabase(dbName string) error {
+	if dg.IsGlobals(dbName) {
+		return nil
+	}
+	ep, err := dg.endpoint()
+	if err != nil {
+		return err
+	}
+	return dg.engine.CreateDatabase(ep, dbName)
+}
+
+// DropDatabase drops a database if it exists
+func (dg *DatabaseGateway) DropDatabase(dbName string) error {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return err
+	}
+	return dg.engine.DropDatabase(ep, dbName)
+}
+
+// TableRowCounts returns the exact number of rows of every base table in a database
+func (dg *DatabaseGateway) TableRowCounts(dbName string) (map[string]int64, error) {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return nil, err
+	}
+	return dg.engine.TableRowCounts(ep, dbName)
+}
+
+// QueryValue runs query against dbName and returns the first column of the first row.
+// ok is false when the query returned no rows or NULL.
+func (dg *DatabaseGateway) QueryValue(dbName string, query string) (value string, ok bool, err error) {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return "", false, err
+	}
+	return dg.engine.QueryValue(ep, dbName, query)
+}
+
+// RestoreDatabase replays an SQL dump read from r into dbName using the engine's client
+func (dg *DatabaseGateway) RestoreDatabase(dbName string, r io.Reader) error {
+	ep, err := dg.endpoint()
+	if err != nil {
+		return err
+	}
+	if engine, ok := dg.globalsEngine(dbName); ok {
+		return engine.RestoreGlobals(ep, r)
+	}
+	return dg.engine.Restore(ep, dbName, r)
+}
+
+// shellQuote quotes an argument for a POSIX shell
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// LinePrefixWriter writes every complete line with a prefix
+type LinePrefixWriter struct {
+	w       io.Writer
+	prefix  string
+	pending []byte
+}
+
+// NewLinePrefixWriter creates a LinePrefixWriter that writes to w
+func NewLinePrefixWriter(w io.Writer, prefix string) *LinePrefixWriter {
+	return &LinePrefixWriter{w: w, prefix: prefix}
+}
+
+func (lw *LinePrefixWriter) Write(p []byte) (int, error) {
+	lw.pending = append(lw.pending, p...)
+	for {
+		i := strings.IndexByte(string(lw.pending), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if _, err := fmt.Fprintf(lw.w, "%s%s", lw.prefix, lw.pending[:i+1]); err != nil {
+			return len(p), err
+		}
+		lw.pending = lw.pending[i+1:]
+	}
+}
+
+// Flush writes a trailing partial line
+func (lw *LinePrefixWriter) Flush() {
+	if len(lw.pending) > 0 {
+		fmt.Fprintf(lw.w, "%s%s\n", lw.prefix, lw.pending)
+		lw.pending = nil
 	}
-	
-	return nil
 }
 
 // Close closes SSH tunnel and cleanup resources
@@ -0,0 +1,23 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLinePrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewLinePrefixWriter(&out, "[shop] ")
+	for _, chunk := range []string{"Successfully stored", " backup\nWarning: a\nWarning", ": b\n", "partial"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := out.String(), "[shop] Successfully stored backup\n[shop] Warning: a\n[shop] Warning: b\n"; got != want {
+		t.Fatalf("before Flush: got %q, want %q", got, want)
+	}
+	w.Flush()
+	if got, want := out.String(), "[shop] Successfully stored backup\n[shop] Warning: a\n[shop] Warning: b\n[shop] partial\n"; got != want {
+		t.Fatalf("after Flush: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,330 @@
+package data
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// DumpStats describes an SQL dump as it was written
+type DumpStats struct {
+	// Size is the number of uncompressed bytes
+	Size int64
+	// Tables maps every table created by the dump to the number of rows inserted into it
+	Tables map[string]int64
+	// Binlog is the binary log position written into the dump as a comment, if any
+	Binlog *domain.BinlogPosition
+}
+
+// maxStatementPrefix bounds how much of a line is buffered to recognize a statement
+const maxStatementPrefix = 1024
+
+// dumpStatsWriter counts tables and rows in mysqldump and pg_dump output as it streams
+// past. For mysqldump it recognizes "CREATE TABLE `t` (" and "INSERT INTO `t` ... VALUES
+// (...),(...);" lines and counts the top-level value tuples, skipping over quoted strings.
+// The column list between the table and VALUES may be of any length.
+// For pg_dump it recognizes "CREATE TABLE schema.t (" and counts the lines of
+// "COPY schema.t (...) FROM stdin;" blocks. The binary log position written by
+// --source-data=2 or --master-data=2 is picked up from its comment.
+type dumpStatsWriter struct {
+	stats DumpStats
+	// sizeOnly skips recognizing statements, for binary streams
+	sizeOnly bool
+	// line buffers the start of the current line until a statement is recognized
+	line     []byte
+	skipLine bool
+	// insertHead is set between the table name of an INSERT and its VALUES, whose end is
+	// kept in tail outside of quoted column names
+	insertHead bool
+	inIdent    bool
+	// state while inside the values of an INSERT statement
+	table    string
+	inInsert bool
+	depth    int
+	inString bool
+	escape   bool
+	// copyTable is set on a COPY line, whose end is kept in tail to find "FROM stdin;"
+	copyTable string
+	tail      []byte
+	// state while inside the data of a COPY statement
+	inCopy  bool
+	copyLen int
+	copyEnd bool
+}
+
+func newDumpStatsWriter() *dumpStatsWriter {
+	return &dumpStatsWriter{stats: DumpStats{Tables: make(map[string]int64)}}
+}
+
+func (d *dumpStatsWriter) Write(p []byte) (int, error) {
+	d.stats.Size += int64(len(p))
+	if d.sizeOnly {
+		return len(p), nil
+	}
+	for _, b := range p {
+		if d.inInsert {
+			d.scanValues(b)
+			continue
+		}
+		if d.inCopy {
+			d.scanCopy(b)
+			continue
+		}
+		if d.insertHead && b != '\n' {
+			d.scanInsertHead(b)
+			continue
+		}
+		if b == '\n' {
+			if !d.skipLine && bytes.HasPrefix(d.line, positionPrefix) {
+				d.parsePosition()
+			}
+			if d.copyTable != "" && bytes.HasSuffix(d.tail, copySuffix) {
+				d.table = d.copyTable
+				d.inCopy = true
+				d.copyLen = 0
+			}
+			d.copyTable = ""
+			d.insertHead = false
+			d.line = d.line[:0]
+			d.skipLine = false
+			continue
+		}
+		if d.copyTable != "" {
+			d.tail = append(d.tail, b)
+			if len(d.tail) > len(copySuffix) {
+				d.tail = d.tail[len(d.tail)-len(copySuffix):]
+			}
+			continue
+		}
+		if d.skipLine {
+			continue
+		}
+		d.line = append(d.line, b)
+		d.scanPrefix()
+	}
+	return len(p), nil
+}
+
+var (
+	insertPrefix   = []byte("INSERT INTO `")
+	createPrefix   = []byte("CREATE TABLE `")
+	pgCreatePrefix = []byte("CREATE TABLE ")
+	copyPrefix     = []byte("COPY ")
+	copySuffix     = []byte(" FROM stdin;")
+	valuesInfix    = []byte(" VALUES ")
+	positionPrefix = []byte("-- ")
+)
+
+// positionPattern matches the binary log position comments of mysqldump, e.g.
+// "-- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;"
+var positionPattern = regexp.MustCompile(`^-- CHANGE (?:MASTER|REPLICATION SOURCE) TO (?:MASTER|SOURCE)_LOG_FILE='([^']+)', (?:MASTER|SOURCE)_LOG_POS=(\d+);`)
+
+// gtidPattern matches the GTID state comments of mariadb-dump --gtid and the built-in
+// dumper, e.g. "-- SET GLOBAL gtid_slave_pos='0-1-42';"
+var gtidPattern = regexp.MustCompile(`^-- SET (?:GLOBAL gtid_slave_pos|@@GLOBAL\.GTID_PURGED)='([^']*)';`)
+
+// scanPrefix checks whether the buffered line starts a statement that is counted
+func (d *dumpStatsWriter) scanPrefix() {
+	switch {
+	case bytes.HasPrefix(d.line, insertPrefix):
+		if table, ok := completeQuotedName(d.line[len(insertPrefix)-1:]); ok {
+			d.table = table
+			d.insertHead = true
+			d.inIdent = false
+			d.tail = append(d.tail[:0], d.line[len(d.line)-1])
+			d.skipLine = true
+			return
+		}
+	case bytes.HasPrefix(d.line, createPrefix):
+		if bytes.HasSuffix(d.line, []byte("` (")) {
+			d.addTable(quotedName(d.line[len(createPrefix)-1:]))
+			d.skipLine = true
+			return
+		}
+	case bytes.HasPrefix(d.line, pgCreatePrefix):
+		if bytes.HasSuffix(d.line, []byte(" (")) {
+			if table, ok := pgName(d.line[len(pgCreatePrefix):]); ok {
+				d.addTable(table)
+			}
+			d.skipLine = true
+			return
+		}
+	case bytes.HasPrefix(d.line, copyPrefix):
+		if table, ok := pgName(d.line[len(copyPrefix):]); ok {
+			d.addTable(table)
+			d.copyTable = table
+			d.tail = append(d.tail[:0], ' ')
+			return
+		}
+	case bytes.HasPrefix(d.line, positionPrefix):
+		// Comments are kept until the end of the line to look for a binary log position
+	case bytes.HasPrefix(insertPrefix, d.line) || bytes.HasPrefix(createPrefix, d.line) ||
+		bytes.HasPrefix(copyPrefix, d.line) || bytes.HasPrefix(positionPrefix, d.line):
+		// Too short to tell yet
+	default:
+		d.skipLine = true
+		return
+	}
+	if len(d.line) > maxStatementPrefix {
+		d.skipLine = true
+	}
+}
+
+// scanInsertHead looks for the VALUES of an INSERT statement after the table name,
+// skipping over the quoted names of its column list
+func (d *dumpStatsWriter) scanInsertHead(b byte) {
+	if b == '`' {
+		d.inIdent = !d.inIdent
+		d.tail = d.tail[:0]
+		return
+	}
+	if d.inIdent {
+		return
+	}
+	d.tail = append(d.tail, b)
+	if len(d.tail) > len(valuesInfix) {
+		d.tail = d.tail[len(d.tail)-len(valuesInfix):]
+	}
+	if bytes.Equal(d.tail, valuesInfix) {
+		d.insertHead = false
+		d.inInsert = true
+		d.depth = 0
+	}
+}
+
+// parsePosition records the binary log position or GTID state of a comment line
+func (d *dumpStatsWriter) parsePosition() {
+	position := positionPattern.FindSubmatch(d.line)
+	gtid := gtidPattern.FindSubmatch(d.line)
+	if position == nil && gtid == nil {
+		return
+	}
+	// mariadb-dump writes the GTID state before the position
+	if d.stats.Binlog == nil {
+		d.stats.Binlog = &domain.BinlogPosition{}
+	}
+	if position != nil {
+		d.stats.Binlog.File = string(position[1])
+		d.stats.Binlog.Position, _ = strconv.ParseInt(string(position[2]), 10, 64)
+	} else {
+		d.stats.Binlog.GTIDSet = string(gtid[1])
+	}
+}
+
+// scanValues counts the value tuples of an INSERT statement
+func (d *dumpStatsWriter) scanValues(b byte) {
+	if d.inString {
+		switch {
+		case d.escape:
+			d.escape = false
+		case b == '\\':
+			d.escape = true
+		case b == '\'':
+			d.inString = false
+		}
+		return
+	}
+	switch b {
+	case '\'':
+		d.inString = true
+	case '(':
+		if d.depth == 0 {
+			d.stats.Tables[d.table]++
+		}
+		d.depth++
+	case ')':
+		d.depth--
+	case ';':
+		if d.depth == 0 {
+			d.inInsert = false
+		}
+	}
+}
+
+// scanCopy counts the rows of a COPY statement, which ends with a "\." line
+func (d *dumpStatsWriter) scanCopy(b byte) {
+	if b != '\n' {
+		if d.copyLen == 0 || d.copyLen == 1 {
+			d.copyEnd = (d.copyLen == 0 && b == '\\') || (d.copyLen == 1 && d.copyEnd && b == '.')
+		}
+		d.copyLen++
+		return
+	}
+	if d.copyLen == 2 && d.copyEnd {
+		d.inCopy = false
+	} else {
+		d.stats.Tables[d.table]++
+	}
+	d.copyLen = 0
+	d.copyEnd = false
+}
+
+// addTable records a table with no rows counted yet
+func (d *dumpStatsWriter) addTable(table string) {
+	if _, ok := d.stats.Tables[table]; !ok {
+		d.stats.Tables[table] = 0
+	}
+}
+
+// pgName returns the possibly schema-qualified and double-quoted name at the start of s,
+// without quotes. ok is false until the name is complete, i.e. followed by a space.
+func pgName(s []byte) (string, bool) {
+	var name strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '"':
+			if i+1 == len(s) {
+				// Can't tell yet whether this is an escaped quote
+				return "", false
+			}
+			if s[i+1] == '"' {
+				name.WriteByte('"')
+				i++
+				continue
+			}
+			quoted = false
+		case quoted:
+			name.WriteByte(c)
+		case c == '"':
+			quoted = true
+		case c == ' ':
+			return name.String(), name.Len() > 0
+		default:
+			name.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+// quotedName returns the backtick-quoted identifier at the start of s
+func quotedName(s []byte) string {
+	name, _ := completeQuotedName(s)
+	return name
+}
+
+// completeQuotedName returns the backtick-quoted identifier at the start of s. ok is false
+// until the identifier is complete, i.e. its closing backtick is followed by another byte.
+func completeQuotedName(s []byte) (string, bool) {
+	var name strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] == '`' {
+			if i+1 == len(s) {
+				// Can't tell yet whether this is an escaped backtick
+				return name.String(), false
+			}
+			if s[i+1] == '`' {
+				name.WriteByte('`')
+				i++
+				continue
+			}
+			return name.String(), true
+		}
+		name.WriteByte(s[i])
+	}
+	return name.String(), false
+}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// scanDump feeds dump to a dumpStatsWriter in chunks of chunkSize bytes (all at once if 0)
+func scanDump(dump string, chunkSize int) DumpStats {
+	w := newDumpStatsWriter()
+	if chunkSize <= 0 {
+		chunkSize = len(dump) + 1
+	}
+	for rest := dump; len(rest) > 0; {
+		n := chunkSize
+		if n > len(rest) {
+			n = len(rest)
+		}
+		w.Write([]byte(rest[:n]))
+		rest = rest[n:]
+	}
+	return w.stats
+}
+
+func TestDumpStatsWriter(t *testing.T) {
+	longColumns := make([]string, 80)
+	for i := range longColumns {
+		longColumns[i] = "`a_rather_long_column_name_" + strings.Repeat("x", i%7) + "`"
+	}
+	longValues := strings.TrimSuffix(strings.Repeat("1,", len(longColumns)), ",")
+
+	tests := []struct {
+		name   string
+		dump   string
+		tables map[string]int64
+		binlog *domain.BinlogPosition
+	}{
+		{
+			name: "mysqldump",
+			dump: "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n" +
+				"--\n-- Table structure for table `customers`\n--\n\n" +
+				"DROP TABLE IF EXISTS `customers`;\n" +
+				"/*!40101 SET @saved_cs_client     = @@character_set_client */;\n" +
+				"CREATE TABLE `customers` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(255) DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`id`)\n" +
+				") ENGINE=InnoDB;\n\n" +
+				"LOCK TABLES `customers` WRITE;\n" +
+				"INSERT INTO `customers` VALUES (1,'Ann'),(2,'O\\'Brien; (Ltd)'),(3,'a),(b'),(4,NULL);\n" +
+				"INSERT INTO `customers` VALUES (5,'back\\\\slash'),(6,'\\'');\n" +
+				"UNLOCK TABLES;\n" +
+				"CREATE TABLE `empty` (\n  `id` int\n);\n" +
+				"CREATE TABLE `we``ird` (\n  `id` int\n);\n" +
+				"INSERT INTO `we``ird` VALUES (1);\n" +
+				"-- Dump completed on 2026-10-16  3:00:00\n",
+			tables: map[string]int64{"customers": 6, "empty": 0, "we`ird": 1},
+		},
+		{
+			name: "native dumper column lists",
+			dump: "CREATE TABLE `wide` (\n  `id` int\n);\n" +
+				"INSERT INTO `wide` (" + strings.Join(longColumns, ",") + ") VALUES (" + longValues + "),(" + longValues + ");\n" +
+				"INSERT INTO `wide` (" + strings.Join(longColumns, ",") + ") VALUES (" + longValues + ");\n" +
+				"CREATE TABLE `tricky` (\n  `id` int\n);\n" +
+				"INSERT INTO `tricky` (`id`,`x VALUES y`,`a``b`) VALUES (1,'VALUES (',0x28),(2,'',NULL);\n",
+			tables: map[string]int64{"wide": 3, "tricky": 2},
+		},
+		{
+			name: "insert statements that are not counted",
+			dump: "CREATE TABLE `t` (\n  `id` int\n);\n" +
+				"INSERT IGNORE INTO `t` VALUES (1);\n" +
+				"/* INSERT INTO `t` VALUES (2); */\n" +
+				"INSERT INTO `t`\n" +
+				"INSERT INTO `t` VALUES (3);\n",
+			tables: map[string]int64{"t": 1},
+		},
+		{
+			name: "pg_dump",
+			dump: "--\n-- PostgreSQL database dump\n--\n\n" +
+				"CREATE TABLE public.orders (\n    id integer NOT NULL,\n    note text\n);\n\n" +
+				"CREATE TABLE sales.\"Order \"\"Items\"\"\" (\n    id integer\n);\n\n" +
+				"CREATE TABLE public.empty (\n    id integer\n);\n\n" +
+				"COPY public.orders (id, note) FROM stdin;\n" +
+				"1\tfirst\n" +
+				"2\t\\\\. not the end\n" +
+				"3\t\\N\n" +
+				"\\.\n\n" +
+				"COPY sales.\"Order \"\"Items\"\"\" (id) FROM stdin;\n" +
+				"1\n" +
+				"\\.\n\n" +
+				"SELECT pg_catalog.setval('public.orders_id_seq', 3, true);\n",
+			tables: map[string]int64{"public.orders": 3, "sales.Order \"Items\"": 1, "public.empty": 0},
+		},
+		{
+			name: "mysqldump --source-data=2",
+			dump: "--\n-- Position to start replication or point-in-time recovery from\n--\n\n" +
+				"-- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;\n\n" +
+				"CREATE TABLE `t` (\n  `id` int\n);\n",
+			tables: map[string]int64{"t": 0},
+			binlog: &domain.BinlogPosition{File: "binlog.000042", Position: 157},
+		},
+		{
+			name:   "mysqldump --master-data=2",
+			dump:   "-- CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=73;\n",
+			tables: map[string]int64{},
+			binlog: &domain.BinlogPosition{File: "mysql-bin.000003", Position: 73},
+		},
+		{
+			name: "mariadb-dump --gtid",
+			dump: "-- Preferably use GTID to start replication from GTID position:\n\n" +
+				"-- SET GLOBAL gtid_slave_pos='0-1-42';\n\n" +
+				"--\n-- Alternately, following is the position of the binary logging from SHOW MASTER STATUS at point of backup.\n--\n\n" +
+				"-- CHANGE MASTER TO MASTER_LOG_FILE='mariadb-bin.000002', MASTER_LOG_POS=344;\n",
+			tables: map[string]int64{},
+			binlog: &domain.BinlogPosition{File: "mariadb-bin.000002", Position: 344, GTIDSet: "0-1-42"},
+		},
+		{
+			name: "native dumper position",
+			dump: "-- CHANGE MASTER TO MASTER_LOG_FILE='binlog.000007', MASTER_LOG_POS=4711;\n" +
+				"-- SET @@GLOBAL.GTID_PURGED='3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n",
+			tables: map[string]int64{},
+			binlog: &domain.BinlogPosition{File: "binlog.000007", Position: 4711, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+		},
+		{
+			name: "position statements in data are ignored",
+			dump: "CREATE TABLE `t` (\n  `id` int\n);\n" +
+				"INSERT INTO `t` VALUES ('\n-- CHANGE MASTER TO MASTER_LOG_FILE=\\'x.000001\\', MASTER_LOG_POS=1;\n');\n" +
+				"  -- CHANGE MASTER TO MASTER_LOG_FILE='y.000001', MASTER_LOG_POS=1;\n",
+			tables: map[string]int64{"t": 1},
+		},
+	}
+	for _, tt := range tests {
+		for _, chunkSize := range []int{0, 1, 7, 1000} {
+			stats := scanDump(tt.dump, chunkSize)
+			if !reflect.DeepEqual(stats.Tables, tt.tables) {
+				t.Errorf("%s (chunks of %d): tables = %v, want %v", tt.name, chunkSize, stats.Tables, tt.tables)
+			}
+			if !reflect.DeepEqual(stats.Binlog, tt.binlog) {
+				t.Errorf("%s (chunks of %d): binlog = %+v, want %+v", tt.name, chunkSize, stats.Binlog, tt.binlog)
+			}
+			if stats.Size != int64(len(tt.dump)) {
+				t.Errorf("%s (chunks of %d): size = %d, want %d", tt.name, chunkSize, stats.Size, len(tt.dump))
+			}
+		}
+	}
+}
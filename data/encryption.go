@@ -0,0 +1,281 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// EncryptionConfig holds the keys used to encrypt or decrypt backups
+type EncryptionConfig struct {
+	// Recipients are public keys (or files containing them) backups are encrypted to
+	Recipients []string
+	// IdentityFile is the private key file used for decryption
+	IdentityFile string
+	// Passphrase unlocks a passphrase-protected identity, or is the secret for aes encryption
+	Passphrase string
+	// KeyFile holds the secret for aes encryption instead of a passphrase
+	KeyFile string
+	// KDF selects the key derivation function for aes encryption (scrypt or argon2id)
+	KDF string
+}
+
+// Encryption encrypts and decrypts backup streams
+type Encryption interface {
+	// Name returns the encryption name used in configuration (e.g. "age")
+	Name() string
+	// Extension returns the file extension appended to encrypted backups (e.g. ".age")
+	Extension() string
+	// Validate reports whether cfg holds the keys needed to encrypt
+	Validate(cfg EncryptionConfig) error
+	// NewWriter wraps w so that everything written to it is encrypted
+	NewWriter(w io.Writer, cfg EncryptionConfig) (io.WriteCloser, error)
+	// NewReader wraps r with a decrypting reader
+	NewReader(r io.Reader, cfg EncryptionConfig) (io.Reader, error)
+}
+
+var encryptions = make(map[string]Encryption)
+
+// RegisterEncryption registers an encryption mode under its name
+func RegisterEncryption(encryption Encryption) {
+	encryptions[encryption.Name()] = encryption
+}
+
+func init() {
+	RegisterEncryption(ageEncryption{})
+	RegisterEncryption(gpgEncryption{})
+	RegisterEncryption(aesEncryption{})
+}
+
+// EncryptionNames returns the names of all registered encryption modes
+func EncryptionNames() []string {
+	names := make([]string, 0, len(encryptions))
+	for name := range encryptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEncryption returns the encryption registered under name
+func GetEncryption(name string) (Encryption, error) {
+	encryption, ok := encryptions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption '%s' (available: %s)", name, strings.Join(EncryptionNames(), ", "))
+	}
+	return encryption, nil
+}
+
+// EncryptionForFile returns the encryption matching a backup file's extension, or nil
+func EncryptionForFile(name string) Encryption {
+	for _, encryption := range encryptions {
+		if strings.HasSuffix(name, encryption.Extension()) {
+			return encryption
+		}
+	}
+	return nil
+}
+
+// trimEncryptionExtension strips a known encryption extension from name
+func trimEncryptionExtension(name string) string {
+	if encryption := EncryptionForFile(name); encryption != nil {
+		return strings.TrimSuffix(name, encryption.Extension())
+	}
+	return name
+}
+
+// DecryptBackup decrypts a backup stream if its file name carries an encryption extension
+func DecryptBackup(r io.Reader, name string, cfg EncryptionConfig) (io.Reader, error) {
+	encryption := EncryptionForFile(name)
+	if encryption == nil {
+		return r, nil
+	}
+	decrypted, err := encryption.NewReader(r, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup %s: %w", name, err)
+	}
+	return decrypted, nil
+}
+
+// DecodeBackup decrypts and decompresses a backup stream based on its file name
+func DecodeBackup(r io.Reader, name string, cfg EncryptionConfig) (io.ReadCloser, error) {
+	return DecodeStream(r, CodecForFile(name), EncryptionForFile(name), cfg)
+}
+
+// DecodeStream decrypts (unless encryption is nil) and decompresses a backup stream
+func DecodeStream(r io.Reader, codec Codec, encryption Encryption, cfg EncryptionConfig) (io.ReadCloser, error) {
+	decrypted := r
+	if encryption != nil {
+		var err error
+		decrypted, err = encryption.NewReader(r, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+	}
+
+	reader, err := codec.NewReader(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	return reader, nil
+}
+
+// HasDecryptionKey reports whether cfg holds the key material encryption needs to decrypt
+func HasDecryptionKey(encryption Encryption, cfg EncryptionConfig) bool {
+	if _, ok := encryption.(aesEncryption); ok {
+		return cfg.Passphrase != "" || cfg.KeyFile != ""
+	}
+	return cfg.IdentityFile != ""
+}
+
+// requireIdentity returns an error when no identity file is configured
+func requireIdentity(cfg EncryptionConfig) error {
+	if cfg.IdentityFile == "" {
+		return fmt.Errorf("no identity configured. Please provide a private key with --identity or ENCRYPTION_IDENTITY")
+	}
+	return nil
+}
+
+// readKeyMaterial returns the value itself for inline keys, or the content of the file it names
+func readKeyMaterial(value string, inlinePrefixes ...string) ([]byte, error) {
+	for _, prefix := range inlinePrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return []byte(value), nil
+		}
+	}
+	data, err := os.ReadFile(expandHome(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return data, nil
+}
+
+// expandHome expands environment variables and a leading ~/ in a path
+func expandHome(path string) string {
+	expanded := os.ExpandEnv(path)
+	if len(expanded) >= 2 && expanded[:2] == "~/" {
+		home, _ := os.UserHomeDir()
+		expanded = home + expanded[1:]
+	}
+	return expanded
+}
+
+// ageEncryption encrypts to age X25519 recipients
+type ageEncryption struct{}
+
+func (ageEncryption) Name() string      { return "age" }
+func (ageEncryption) Extension() string { return ".age" }
+
+func (ageEncryption) Validate(cfg EncryptionConfig) error {
+	if len(cfg.Recipients) == 0 {
+		return fmt.Errorf("age encryption requires at least one recipient (--recipient or ENCRYPTION_RECIPIENTS)")
+	}
+	return nil
+}
+
+func (ageEncryption) NewWriter(w io.Writer, cfg EncryptionConfig) (io.WriteCloser, error) {
+	var recipients []age.Recipient
+	for _, value := range cfg.Recipients {
+		data, err := readKeyMaterial(value, "age1")
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := age.ParseRecipients(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %s: %w", value, err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("age encryption requires at least one recipient")
+	}
+	return age.Encrypt(w, recipients...)
+}
+
+func (ageEncryption) NewReader(r io.Reader, cfg EncryptionConfig) (io.Reader, error) {
+	if err := requireIdentity(cfg); err != nil {
+		return nil, err
+	}
+	data, err := readKeyMaterial(cfg.IdentityFile, "AGE-SECRET-KEY-")
+	if err != nil {
+		return nil, err
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	return age.Decrypt(r, identities...)
+}
+
+// gpgEncryption encrypts to OpenPGP public keys
+type gpgEncryption struct{}
+
+func (gpgEncryption) Name() string      { return "gpg" }
+func (gpgEncryption) Extension() string { return ".gpg" }
+
+// readPGPKeyRing reads an armored or binary OpenPGP key ring
+func readPGPKeyRing(data []byte) (openpgp.EntityList, error) {
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+func (gpgEncryption) Validate(cfg EncryptionConfig) error {
+	if len(cfg.Recipients) == 0 {
+		return fmt.Errorf("gpg encryption requires at least one recipient key (--recipient or ENCRYPTION_RECIPIENTS)")
+	}
+	return nil
+}
+
+func (gpgEncryption) NewWriter(w io.Writer, cfg EncryptionConfig) (io.WriteCloser, error) {
+	var recipients openpgp.EntityList
+	for _, value := range cfg.Recipients {
+		data, err := readKeyMaterial(value, "-----BEGIN PGP")
+		if err != nil {
+			return nil, err
+		}
+		entities, err := readPGPKeyRing(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OpenPGP recipient %s: %w", value, err)
+		}
+		recipients = append(recipients, entities...)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("gpg encryption requires at least one recipient key")
+	}
+	return openpgp.Encrypt(w, recipients, nil, &openpgp.FileHints{IsBinary: true}, nil)
+}
+
+func (gpgEncryption) NewReader(r io.Reader, cfg EncryptionConfig) (io.Reader, error) {
+	if err := requireIdentity(cfg); err != nil {
+		return nil, err
+	}
+	data, err := readKeyMaterial(cfg.IdentityFile, "-----BEGIN PGP")
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := readPGPKeyRing(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenPGP private key: %w", err)
+	}
+	if cfg.Passphrase != "" {
+		for _, entity := range keyring {
+			if err := entity.DecryptPrivateKeys([]byte(cfg.Passphrase)); err != nil {
+				return nil, fmt.Errorf("failed to unlock OpenPGP private key: %w", err)
+			}
+		}
+	}
+
+	md, err := openpgp.ReadMessage(r, keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return md.UnverifiedBody, nil
+}
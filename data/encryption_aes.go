@@ -0,0 +1,315 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// aesEncryption encrypts with a passphrase or key file using chunked AES-256-GCM.
+//
+// File format (all integers big-endian):
+//
+//	magic      "DBBKAES1"
+//	kdf        1 byte (1 = scrypt, 2 = argon2id)
+//	params     3 x uint32 (scrypt: log2 N, r, p; argon2id: time, memory KiB, threads)
+//	salt       16 bytes
+//	chunk size uint32
+//	chunks     ciphertext || 16-byte tag, each sealing chunk size bytes of plaintext
+//	           (the final chunk may be shorter)
+//
+// Each chunk uses a 12-byte nonce made of an 11-byte counter and a final-chunk flag,
+// with the header as additional data, so reordering, truncation and header tampering
+// are all detected.
+type aesEncryption struct{}
+
+const (
+	aesMagic        = "DBBKAES1"
+	aesHeaderSize   = len(aesMagic) + 1 + 3*4 + aesSaltSize + 4
+	aesSaltSize     = 16
+	aesChunkSize    = 64 * 1024
+	aesMaxChunkSize = 16 * 1024 * 1024
+
+	kdfScrypt   byte = 1
+	kdfArgon2id byte = 2
+)
+
+// Default KDF parameters; the scrypt settings need 128 MB, argon2id follows RFC 9106
+var (
+	defaultScryptParams   = [3]uint32{17, 8, 1}
+	defaultArgon2idParams = [3]uint32{3, 64 * 1024, 4}
+)
+
+func (aesEncryption) Name() string      { return "aes" }
+func (aesEncryption) Extension() string { return ".aes" }
+
+func (aesEncryption) Validate(cfg EncryptionConfig) error {
+	if cfg.Passphrase == "" && cfg.KeyFile == "" {
+		return fmt.Errorf("aes encryption requires a passphrase (ENCRYPTION_PASSPHRASE) or key file (--key-file or ENCRYPTION_KEY_FILE)")
+	}
+	if _, err := aesKDF(cfg.KDF); err != nil {
+		return err
+	}
+	return nil
+}
+
+// aesKDF maps a KDF name to its header identifier
+func aesKDF(name string) (byte, error) {
+	switch strings.ToLower(name) {
+	case "", "scrypt":
+		return kdfScrypt, nil
+	case "argon2id", "argon2":
+		return kdfArgon2id, nil
+	}
+	return 0, fmt.Errorf("unknown key derivation function '%s' (available: scrypt, argon2id)", name)
+}
+
+// aesSecret returns the passphrase, or the content of the key file
+func aesSecret(cfg EncryptionConfig) ([]byte, error) {
+	if cfg.KeyFile != "" {
+		data, err := readKeyMaterial(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		// Tolerate key files written with a trailing newline
+		data = bytes.TrimRight(data, "\r\n")
+		if len(data) == 0 {
+			return nil, fmt.Errorf("key file %s is empty", cfg.KeyFile)
+		}
+		return data, nil
+	}
+	if cfg.Passphrase != "" {
+		return []byte(cfg.Passphrase), nil
+	}
+	return nil, fmt.Errorf("aes encryption requires a passphrase (ENCRYPTION_PASSPHRASE) or key file (--key-file or ENCRYPTION_KEY_FILE)")
+}
+
+// deriveAESKey derives the 256-bit file key from secret with the header's KDF parameters
+func deriveAESKey(secret []byte, kdf byte, params [3]uint32, salt []byte) ([]byte, error) {
+	switch kdf {
+	case kdfScrypt:
+		if params[0] < 10 || params[0] > 22 || params[1] == 0 || params[1] > 32 || params[2] == 0 || params[2] > 16 {
+			return nil, fmt.Errorf("unsupported scrypt parameters")
+		}
+		return scrypt.Key(secret, salt, 1<<params[0], int(params[1]), int(params[2]), 32)
+	case kdfArgon2id:
+		if params[0] == 0 || params[0] > 32 || params[1] < 8*1024 || params[1] > 4*1024*1024 || params[2] == 0 || params[2] > 255 {
+			return nil, fmt.Errorf("unsupported argon2id parameters")
+		}
+		return argon2.IDKey(secret, salt, params[0], params[1], uint8(params[2]), 32), nil
+	}
+	return nil, fmt.Errorf("unknown key derivation function %d", kdf)
+}
+
+// newAESGCM creates the AEAD for a derived key
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// aesNonce builds the nonce for chunk counter, flagging the final chunk
+func aesNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func (e aesEncryption) NewWriter(w io.Writer, cfg EncryptionConfig) (io.WriteCloser, error) {
+	secret, err := aesSecret(cfg)
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := aesKDF(cfg.KDF)
+	if err != nil {
+		return nil, err
+	}
+	params := defaultScryptParams
+	if kdf == kdfArgon2id {
+		params = defaultArgon2idParams
+	}
+
+	salt := make([]byte, aesSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := deriveAESKey(secret, kdf, params, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, aesHeaderSize)
+	header = append(header, aesMagic...)
+	header = append(header, kdf)
+	for _, param := range params {
+		header = binary.BigEndian.AppendUint32(header, param)
+	}
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, aesChunkSize)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &aesWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, aesChunkSize)}, nil
+}
+
+// aesWriter buffers plaintext into chunks; a full chunk is only sealed once more data
+// arrives so that Close can mark the real final chunk
+type aesWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (a *aesWriter) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, errors.New("write to closed encryptor")
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(a.buf) == aesChunkSize {
+			if err := a.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(a.buf[len(a.buf):aesChunkSize], p)
+		a.buf = a.buf[:len(a.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (a *aesWriter) flush(last bool) error {
+	sealed := a.aead.Seal(nil, aesNonce(a.counter, last), a.buf, a.header)
+	a.counter++
+	a.buf = a.buf[:0]
+	_, err := a.w.Write(sealed)
+	return err
+}
+
+func (a *aesWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	return a.flush(true)
+}
+
+func (e aesEncryption) NewReader(r io.Reader, cfg EncryptionConfig) (io.Reader, error) {
+	secret, err := aesSecret(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, aesHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if string(header[:len(aesMagic)]) != aesMagic {
+		return nil, fmt.Errorf("not an aes encrypted backup")
+	}
+	offset := len(aesMagic)
+	kdf := header[offset]
+	offset++
+	var params [3]uint32
+	for i := range params {
+		params[i] = binary.BigEndian.Uint32(header[offset:])
+		offset += 4
+	}
+	salt := header[offset : offset+aesSaltSize]
+	offset += aesSaltSize
+	chunkSize := binary.BigEndian.Uint32(header[offset:])
+	if chunkSize == 0 || chunkSize > aesMaxChunkSize {
+		return nil, fmt.Errorf("unsupported chunk size %d", chunkSize)
+	}
+
+	key, err := deriveAESKey(secret, kdf, params, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		header: header,
+		chunk:  make([]byte, int(chunkSize)+aead.Overhead()),
+	}, nil
+}
+
+// aesReader decrypts and authenticates one chunk at a time
+type aesReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	for len(a.plain) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, a.plain)
+	a.plain = a.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the following chunk; it is final when no data follows it
+func (a *aesReader) next() error {
+	n, err := io.ReadFull(a.r, a.chunk)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return fmt.Errorf("encrypted backup is truncated")
+		}
+		return err
+	}
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		if _, peekErr := a.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := a.aead.Open(a.chunk[:0], aesNonce(a.counter, last), a.chunk[:n], a.header)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt backup: wrong passphrase or key, or the file is corrupted or truncated")
+	}
+	a.counter++
+	a.plain = plain
+	a.done = last
+	return nil
+}
@@ -0,0 +1,232 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fastKDF lowers the KDF cost so that tests don't spend seconds deriving keys
+func fastKDF(t *testing.T) {
+	t.Helper()
+	scryptParams, argon2idParams := defaultScryptParams, defaultArgon2idParams
+	defaultScryptParams = [3]uint32{10, 8, 1}
+	defaultArgon2idParams = [3]uint32{1, 8 * 1024, 1}
+	t.Cleanup(func() {
+		defaultScryptParams, defaultArgon2idParams = scryptParams, argon2idParams
+	})
+}
+
+func aesEncrypt(t *testing.T, plain []byte, cfg EncryptionConfig) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := aesEncryption{}.NewWriter(&out, cfg)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func aesDecrypt(sealed []byte, cfg EncryptionConfig) ([]byte, error) {
+	r, err := aesEncryption{}.NewReader(bytes.NewReader(sealed), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestAESRoundTrip(t *testing.T) {
+	fastKDF(t)
+	sizes := []int{0, 1, aesChunkSize - 1, aesChunkSize, aesChunkSize + 1, 3*aesChunkSize + 17}
+	for _, kdf := range []string{"scrypt", "argon2id"} {
+		cfg := EncryptionConfig{Passphrase: "correct horse", KDF: kdf}
+		for _, size := range sizes {
+			plain := randomBytes(t, size)
+			sealed := aesEncrypt(t, plain, cfg)
+			got, err := aesDecrypt(sealed, EncryptionConfig{Passphrase: "correct horse"})
+			if err != nil {
+				t.Fatalf("%s/%d: decrypt: %v", kdf, size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("%s/%d: round trip returned %d bytes that differ from the input", kdf, size, len(got))
+			}
+		}
+	}
+}
+
+func TestAESChunkLayout(t *testing.T) {
+	fastKDF(t)
+	cfg := EncryptionConfig{Passphrase: "secret"}
+	tests := []struct {
+		size   int
+		chunks int
+	}{
+		{0, 1},
+		{1, 1},
+		// A full chunk is only sealed once more data follows, so it is the final chunk
+		{aesChunkSize, 1},
+		{aesChunkSize + 1, 2},
+	}
+	for _, tt := range tests {
+		sealed := aesEncrypt(t, randomBytes(t, tt.size), cfg)
+		want := aesHeaderSize + tt.size + tt.chunks*16
+		if len(sealed) != want {
+			t.Errorf("size %d: got %d encrypted bytes, want %d (%d chunk(s))", tt.size, len(sealed), want, tt.chunks)
+		}
+	}
+}
+
+func TestAESWriterSplitWrites(t *testing.T) {
+	fastKDF(t)
+	cfg := EncryptionConfig{Passphrase: "secret"}
+	plain := randomBytes(t, 2*aesChunkSize+5)
+	var out bytes.Buffer
+	w, err := aesEncryption{}.NewWriter(&out, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rest := plain; len(rest) > 0; {
+		n := 1000
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := aesDecrypt(out.Bytes(), cfg)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("round trip of split writes differs from the input")
+	}
+}
+
+func TestAESDetectsTampering(t *testing.T) {
+	fastKDF(t)
+	cfg := EncryptionConfig{Passphrase: "secret"}
+	tag := 16
+
+	tests := []struct {
+		name   string
+		size   int
+		tamper func(sealed []byte) []byte
+	}{
+		{"drop final chunk", 2*aesChunkSize + 10, func(s []byte) []byte {
+			return s[:aesHeaderSize+2*(aesChunkSize+tag)]
+		}},
+		{"drop only chunk", 0, func(s []byte) []byte {
+			return s[:aesHeaderSize]
+		}},
+		{"cut final chunk short", aesChunkSize + 10, func(s []byte) []byte {
+			return s[:len(s)-1]
+		}},
+		{"flip byte in first chunk", aesChunkSize + 10, func(s []byte) []byte {
+			s[aesHeaderSize+5] ^= 0x01
+			return s
+		}},
+		{"flip byte in final chunk", aesChunkSize + 10, func(s []byte) []byte {
+			s[len(s)-tag-1] ^= 0x80
+			return s
+		}},
+		{"flip byte in tag", 100, func(s []byte) []byte {
+			s[len(s)-1] ^= 0x01
+			return s
+		}},
+		{"swap chunks", 2*aesChunkSize + 10, func(s []byte) []byte {
+			chunk := aesChunkSize + tag
+			first := append([]byte{}, s[aesHeaderSize:aesHeaderSize+chunk]...)
+			copy(s[aesHeaderSize:], s[aesHeaderSize+chunk:aesHeaderSize+2*chunk])
+			copy(s[aesHeaderSize+chunk:], first)
+			return s
+		}},
+		{"change chunk size in header", 100, func(s []byte) []byte {
+			s[aesHeaderSize-1] ^= 0x01
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed := tt.tamper(aesEncrypt(t, randomBytes(t, tt.size), cfg))
+			if _, err := aesDecrypt(sealed, cfg); err == nil {
+				t.Fatal("decrypting a tampered backup succeeded")
+			}
+		})
+	}
+}
+
+func TestAESWrongSecret(t *testing.T) {
+	fastKDF(t)
+	sealed := aesEncrypt(t, []byte("CREATE TABLE t (id int);"), EncryptionConfig{Passphrase: "right"})
+
+	_, err := aesDecrypt(sealed, EncryptionConfig{Passphrase: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+	if _, err := aesDecrypt(sealed, EncryptionConfig{}); err == nil {
+		t.Fatal("decrypting without a passphrase or key file succeeded")
+	}
+}
+
+func TestAESKDF(t *testing.T) {
+	fastKDF(t)
+	cfg := EncryptionConfig{Passphrase: "secret"}
+
+	if err := (aesEncryption{}).Validate(EncryptionConfig{Passphrase: "secret", KDF: "bcrypt"}); err == nil {
+		t.Error("Validate accepted an unknown KDF")
+	}
+	if _, err := (aesEncryption{}).NewWriter(io.Discard, EncryptionConfig{Passphrase: "secret", KDF: "pbkdf2"}); err == nil {
+		t.Error("NewWriter accepted an unknown KDF")
+	}
+
+	kdfOffset := len(aesMagic)
+	tests := []struct {
+		name   string
+		tamper func(sealed []byte)
+	}{
+		// The key derived with the other KDF doesn't open the chunks
+		{"other kdf", func(s []byte) { s[kdfOffset] = kdfArgon2id }},
+		{"unknown kdf", func(s []byte) { s[kdfOffset] = 9 }},
+		// log2 N of 30 would need far too much memory
+		{"unsupported parameters", func(s []byte) { s[kdfOffset+4] = 30 }},
+		{"changed parameters", func(s []byte) { s[kdfOffset+4] = 11 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed := aesEncrypt(t, []byte("data"), cfg)
+			tt.tamper(sealed)
+			if _, err := aesDecrypt(sealed, cfg); err == nil {
+				t.Fatal("decrypting with a tampered KDF header succeeded")
+			}
+		})
+	}
+}
+
+func TestAESNotEncrypted(t *testing.T) {
+	_, err := aesDecrypt([]byte(strings.Repeat("-- plain SQL dump\n", 10)), EncryptionConfig{Passphrase: "secret"})
+	if err == nil || !strings.Contains(err.Error(), "not an aes encrypted backup") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// encryptionRoundTrip encrypts and gzips input, then decodes it again via DecodeBackup
+func encryptionRoundTrip(t *testing.T, name string, encryptCfg, decryptCfg EncryptionConfig) {
+	t.Helper()
+	encryption, err := GetEncryption(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte(strings.Repeat("INSERT INTO `users` VALUES (1,'alice');\n", 500))
+
+	var encrypted bytes.Buffer
+	ew, err := encryption.NewWriter(&encrypted, encryptCfg)
+	if err != nil {
+		t.Fatalf("%s: NewWriter: %v", name, err)
+	}
+	gw := gzip.NewWriter(ew)
+	if _, err := gw.Write(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("%s: Close: %v", name, err)
+	}
+	if bytes.Contains(encrypted.Bytes(), []byte("alice")) {
+		t.Fatalf("%s: output contains plaintext", name)
+	}
+
+	fileName := "shop-20261016030000.sql.gz" + encryption.Extension()
+	reader, err := DecodeBackup(&encrypted, fileName, decryptCfg)
+	if err != nil {
+		t.Fatalf("%s: DecodeBackup: %v", name, err)
+	}
+	output, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("%s: read: %v", name, err)
+	}
+	if !bytes.Equal(output, input) {
+		t.Errorf("%s: round trip returned %d bytes, want %d", name, len(output), len(input))
+	}
+}
+
+func TestAgeEncryptionRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "backup.key")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	encryptCfg := EncryptionConfig{Recipients: []string{identity.Recipient().String()}}
+	encryptionRoundTrip(t, "age", encryptCfg, EncryptionConfig{IdentityFile: identityFile})
+	encryptionRoundTrip(t, "age", encryptCfg, EncryptionConfig{IdentityFile: identity.String()})
+}
+
+func TestGPGEncryptionRoundTrip(t *testing.T) {
+	entity, err := openpgp.NewEntity("Backup", "", "backup@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var public, private bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if err := entity.SerializePrivate(&private, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	publicFile := filepath.Join(dir, "backup.pub.asc")
+	privateFile := filepath.Join(dir, "backup.key")
+	if err := os.WriteFile(publicFile, public.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(privateFile, private.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	encryptionRoundTrip(t, "gpg", EncryptionConfig{Recipients: []string{publicFile}}, EncryptionConfig{IdentityFile: privateFile})
+	encryptionRoundTrip(t, "gpg", EncryptionConfig{Recipients: []string{public.String()}}, EncryptionConfig{IdentityFile: privateFile})
+}
+
+func TestEncryptionForFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption string
+		codec      string
+		backup     bool
+	}{
+		{"shop-20261016030000.sql.gz.age", "age", "gzip", true},
+		{"shop-20261016030000.sql.zst.gpg", "gpg", "zstd", true},
+		{"shop-20261016030000.sql.age", "age", "none", true},
+		{"shop-20261016030000.sql.gz", "", "gzip", true},
+		{"backup.key.age", "age", "none", false},
+	}
+	for _, tt := range tests {
+		got := ""
+		if encryption := EncryptionForFile(tt.name); encryption != nil {
+			got = encryption.Name()
+		}
+		if got != tt.encryption {
+			t.Errorf("EncryptionForFile(%s) = %q, want %q", tt.name, got, tt.encryption)
+		}
+		if codec := CodecForFile(tt.name).Name(); codec != tt.codec {
+			t.Errorf("CodecForFile(%s) = %s, want %s", tt.name, codec, tt.codec)
+		}
+		if backup := isBackupFile(tt.name); backup != tt.backup {
+			t.Errorf("isBackupFile(%s) = %v, want %v", tt.name, backup, tt.backup)
+		}
+	}
+}
+
+func TestDecodeBackupRequiresIdentity(t *testing.T) {
+	_, err := DecodeBackup(strings.NewReader("ciphertext"), "shop-20261016030000.sql.gz.age", EncryptionConfig{})
+	if err == nil || !strings.Contains(err.Error(), "--identity") {
+		t.Errorf("DecodeBackup without identity: error = %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// Endpoint is the server an engine connects to. Host and port are those of the
+// SSH tunnel when the connection uses one.
+type Endpoint struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+}
+
+// Engine implements the server-specific parts of backing up and restoring databases.
+// The DatabaseGateway takes care of SSH tunnels, compression and dump statistics.
+type Engine interface {
+	// Name returns the name the engine is selected by, e.g. "mysql"
+	Name() string
+	// DefaultPort is used when a connection has no port
+	DefaultPort() int
+	// SystemDatabases are never backed up
+	SystemDatabases() []string
+	ListDatabases(ep Endpoint) ([]string, error)
+	// ServerVersion returns the server flavor, e.g. "mysql" or "mariadb", and its version
+	ServerVersion(ep Endpoint) (flavor string, version string, err error)
+	// DumperInfo describes the dump tool; its version is empty when it can't be run
+	DumperInfo() domain.DumperInfo
+	// Dump writes a plain SQL dump of dbName to stdout
+	Dump(ep Endpoint, dbName string, stdout io.Writer, stderr io.Writer) error
+	// Restore replays a plain SQL dump read from r into dbName
+	Restore(ep Endpoint, dbName string, r io.Reader) error
+	// IsDatabaseEmpty reports whether a database has no tables (or does not exist)
+	IsDatabaseEmpty(ep Endpoint, dbName string) (bool, error)
+	// CreateDatabase creates a database if it does not exist yet
+	CreateDatabase(ep Endpoint, dbName string) error
+	// DropDatabase drops a database if it exists
+	DropDatabase(ep Endpoint, dbName string) error
+	// TableRowCounts returns the exact number of rows of every table, named as in the dump
+	TableRowCounts(ep Endpoint, dbName string) (map[string]int64, error)
+	// QueryValue runs query against dbName and returns the first column of the first row.
+	// ok is false when the query returned no rows or NULL.
+	QueryValue(ep Endpoint, dbName string, query string) (value string, ok bool, err error)
+}
+
+// GlobalsEngine is implemented by engines that can back up server-wide objects such
+// as roles and tablespaces separately from the databases
+type GlobalsEngine interface {
+	Engine
+	// DumpsGlobals reports whether globals are backed up with every run
+	DumpsGlobals() bool
+	DumpGlobals(ep Endpoint, stdout io.Writer, stderr io.Writer) error
+	RestoreGlobals(ep Endpoint, r io.Reader) error
+}
+
+// GlobalsDatabase is the pseudo-database server-wide objects are backed up as
+const GlobalsDatabase = "_globals"
+
+// PhysicalEngine is implemented by engines that can take physical hot backups: a copy of
+// the server's data files streamed by a tool running on the database host
+type PhysicalEngine interface {
+	Engine
+	// PhysicalTools returns the backup tool and its stream extractor for a server of the
+	// given flavor and version, e.g. xtrabackup and xbstream
+	PhysicalTools(flavor string, version string) (backup string, extract string)
+	// PhysicalBackupFlags are the options that make the backup tool stream to stdout
+	PhysicalBackupFlags() []string
+	// PhysicalConnectionArgs are the options that connect the backup tool to the server at
+	// ep, as seen from the database host
+	PhysicalConnectionArgs(ep Endpoint) []string
+}
+
+// PhysicalDatabase is the pseudo-database physical backups of a server are stored as
+const PhysicalDatabase = "_physical"
+
+// PhysicalExtension is the file extension of physical backups before compression
+const PhysicalExtension = ".xbstream"
+
+// BinlogEngine is implemented by engines that can archive the server's binary logs and
+// replay them for point-in-time recovery
+type BinlogEngine interface {
+	Engine
+	// ListBinlogs returns the binary logs on the server, oldest first; the last one is
+	// still being written
+	ListBinlogs(ep Endpoint) ([]string, error)
+	// FlushBinlogs closes the binary log being written and starts a new one
+	FlushBinlogs(ep Endpoint) error
+	// BinlogDumperInfo describes the tool binary logs are fetched with
+	BinlogDumperInfo() domain.DumperInfo
+	// FetchBinlog copies a binary log from the server into dir and returns its path
+	FetchBinlog(ep Endpoint, name string, dir string, stderr io.Writer) (string, error)
+	// ReplayBinlogs applies the events of dbName in the binary log files to targetDB,
+	// starting at position start of the first file and stopping after the events of stop
+	ReplayBinlogs(ep Endpoint, dbName string, targetDB string, files []string, start int64, stop time.Time) error
+}
+
+// BinlogDatabase is the pseudo-database archived binary logs are stored as
+const BinlogDatabase = "_binlog"
+
+// BinlogExtension is the file extension of archived binary logs before compression
+const BinlogExtension = ".binlog"
+
+// EngineConfig holds the settings used to create an engine. Empty fields fall back
+// to the engine's .env settings.
+type EngineConfig struct {
+	Name string
+	// DumpPath and ClientPath are the dump tool and SQL client, e.g. mysqldump and mysql
+	DumpPath   string
+	ClientPath string
+	// DumpAllPath is pg_dumpall, used for Postgres globals
+	DumpAllPath string
+	// Globals backs up server-wide objects as GlobalsDatabase (Postgres roles and
+	// tablespaces, MariaDB users and grants)
+	Globals bool
+	// IncludePostgresDB backs up the "postgres" maintenance database (Postgres)
+	IncludePostgresDB bool
+	// DumpMethod is "mysqldump" or "native", the built-in dumper (MySQL)
+	DumpMethod string
+	// PhysicalPath is the physical backup tool, xtrabackup or mariabackup (MySQL)
+	PhysicalPath string
+	// Binlog records the binary log position in dumps, for point-in-time recovery (MySQL)
+	Binlog bool
+	// BinlogPath is the tool binary logs are fetched and replayed with, mysqlbinlog (MySQL)
+	BinlogPath string
+}
+
+// EngineFactory creates an engine from its configuration
+type EngineFactory func(cfg EngineConfig) (Engine, error)
+
+var engines = make(map[string]EngineFactory)
+
+// RegisterEngine registers a database engine under a name
+func RegisterEngine(name string, factory EngineFactory) {
+	engines[strings.ToLower(name)] = factory
+}
+
+// EngineNames returns the names of all registered engines
+func EngineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewEngine creates the engine selected by cfg.Name, falling back to DB_ENGINE and MySQL
+func NewEngine(cfg EngineConfig) (Engine, error) {
+	name := strings.ToLower(firstNonEmpty(cfg.Name, os.Getenv("DB_ENGINE"), "mysql"))
+	factory, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine '%s' (available: %s)", name, strings.Join(EngineNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+// resolveTool returns the absolute path of a client tool; setting names the .env
+// setting that configures it, for the error message
+func resolveTool(path string, setting string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%s not found. Set %s in .env or ensure '%s' is in PATH", filepath.Base(path), setting, path)
+	}
+	return resolved, nil
+}
+
+// toolVersion returns the first line printed by "tool --version", or "" if it can't be run
+func toolVersion(path string, setting string) string {
+	tool, err := resolveTool(path, setting)
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command(tool, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+}
+
+// envBool reports whether a boolean .env setting is true
+func envBool(key string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(key))
+	return value
+}
@@ -0,0 +1,496 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func init() {
+	RegisterEngine("mysql", newMySQLEngine)
+	RegisterEngine("mariadb", newMySQLEngine)
+}
+
+// mysqldumpFlags are the dump options passed to mysqldump besides the connection settings
+var mysqldumpFlags = []string{"--single-transaction", "--quick", "--skip-lock-tables"}
+
+// mariadbDumpFlags back up users and grants with mariadb-dump. Only the system tables are
+// dumped in logical form; the mysql database itself is left out.
+var mariadbDumpFlags = []string{"--system=users", "--insert-ignore", "--no-data", "--no-create-info",
+	"--no-create-db", "--skip-triggers", "--databases", "mysql"}
+
+// Server flavors told apart by the MySQL engine
+const (
+	flavorMySQL   = "mysql"
+	flavorMariaDB = "mariadb"
+)
+
+// toolVersionPattern matches the version in "mysqldump  Ver 8.0.36 for Linux" and
+// "mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB, for Linux"
+var toolVersionPattern = regexp.MustCompile(`(?:Distrib|Ver) (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// mysqlEngine backs up MySQL and MariaDB with mysqldump and restores with the mysql
+// client. For MariaDB servers it switches to mariadb-dump and mariadb unless tool
+// paths are configured.
+type mysqlEngine struct {
+	mysqldumpPath string
+	mysqlPath     string
+	// autoDump and autoClient are set when the tool paths weren't configured
+	autoDump   bool
+	autoClient bool
+	// users backs up MariaDB users and grants as GlobalsDatabase
+	users bool
+	// native dumps with the built-in dumper instead of mysqldump
+	native bool
+	// physicalPath is the configured xtrabackup or mariabackup, if any
+	physicalPath string
+	// binlog records the binary log position in dumps; mysqlbinlogPath fetches and replays
+	// binary logs, and autoBinlog is set when its path wasn't configured
+	binlog          bool
+	mysqlbinlogPath string
+	autoBinlog      bool
+
+	// mu guards the detected server and the tool versions
+	mu           sync.Mutex
+	flavor       string
+	version      string
+	toolVersions map[string]string
+}
+
+func newMySQLEngine(cfg EngineConfig) (Engine, error) {
+	method := strings.ToLower(firstNonEmpty(cfg.DumpMethod, os.Getenv("DUMP_METHOD"), dumpMethodMysqldump))
+	if method != dumpMethodMysqldump && method != dumpMethodNative {
+		return nil, fmt.Errorf("unknown dump method '%s' (available: %s, %s)", method, dumpMethodMysqldump, dumpMethodNative)
+	}
+
+	dumpPath := firstNonEmpty(cfg.DumpPath, os.Getenv("MYSQLDUMP_PATH"))
+	clientPath := firstNonEmpty(cfg.ClientPath, os.Getenv("MYSQL_PATH"))
+	binlogPath := firstNonEmpty(cfg.BinlogPath, os.Getenv("MYSQLBINLOG_PATH"))
+	return &mysqlEngine{
+		mysqldumpPath:   firstNonEmpty(dumpPath, "mysqldump"),
+		mysqlPath:       firstNonEmpty(clientPath, "mysql"),
+		autoDump:        dumpPath == "",
+		autoClient:      clientPath == "",
+		users:           cfg.Globals || envBool("MARIADB_DUMP_USERS"),
+		native:          method == dumpMethodNative,
+		physicalPath:    firstNonEmpty(cfg.PhysicalPath, os.Getenv("XTRABACKUP_PATH")),
+		binlog:          cfg.Binlog || envBool("BINLOG_ARCHIVE"),
+		mysqlbinlogPath: firstNonEmpty(binlogPath, "mysqlbinlog"),
+		autoBinlog:      binlogPath == "",
+		toolVersions:    make(map[string]string),
+	}, nil
+}
+
+func (e *mysqlEngine) Name() string     { return "mysql" }
+func (e *mysqlEngine) DefaultPort() int { return 3306 }
+
+func (e *mysqlEngine) SystemDatabases() []string {
+	return []string{"information_schema", "performance_schema", "mysql", "sys"}
+}
+
+// open opens a SQL connection with dbName as the default database
+func (e *mysqlEngine) open(ep Endpoint, dbName string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", ep.User, ep.Password, ep.Host, ep.Port, url.PathEscape(dbName))
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
+	}
+	return db, nil
+}
+
+func (e *mysqlEngine) ListDatabases(ep Endpoint) ([]string, error) {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// The server is detected over the same connection, before any dump is started
+	if err := e.detectServer(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SHOW DATABASES")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// detectServer records the flavor and version of the server db is connected to
+func (e *mysqlEngine) detectServer(db *sql.DB) error {
+	var version string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return fmt.Errorf("failed to query server version: %w", err)
+	}
+	flavor := flavorMySQL
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		flavor = flavorMariaDB
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flavor, e.version = flavor, version
+	return nil
+}
+
+// server returns the detected flavor and version, connecting to the server when it
+// hasn't been detected yet
+func (e *mysqlEngine) server(ep Endpoint) (string, string, error) {
+	e.mu.Lock()
+	flavor, version := e.flavor, e.version
+	e.mu.Unlock()
+	if flavor != "" {
+		return flavor, version, nil
+	}
+
+	db, err := e.open(ep, "")
+	if err != nil {
+		return "", "", err
+	}
+	defer db.Close()
+	if err := e.detectServer(db); err != nil {
+		return "", "", err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flavor, e.version, nil
+}
+
+// detectedFlavor returns the flavor found by ListDatabases, or "" before it ran
+func (e *mysqlEngine) detectedFlavor() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flavor
+}
+
+// lacksColumnStatistics reports whether the detected server has no
+// information_schema.COLUMN_STATISTICS table, which mysqldump 8 queries by default
+func (e *mysqlEngine) lacksColumnStatistics() bool {
+	e.mu.Lock()
+	flavor, version := e.flavor, e.version
+	e.mu.Unlock()
+	if flavor == flavorMariaDB {
+		return true
+	}
+	if flavor == "" {
+		return false
+	}
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return err == nil && major < 8
+}
+
+func (e *mysqlEngine) ServerVersion(ep Endpoint) (string, string, error) {
+	return e.server(ep)
+}
+
+// dumpTool returns the dump tool for the detected server: mariadb-dump for MariaDB
+// when no path is configured and it is installed, mysqldump otherwise
+func (e *mysqlEngine) dumpTool() string {
+	if e.autoDump && e.detectedFlavor() == flavorMariaDB {
+		if _, err := exec.LookPath("mariadb-dump"); err == nil {
+			return "mariadb-dump"
+		}
+	}
+	return e.mysqldumpPath
+}
+
+// clientTool returns the SQL client for the detected server, mariadb or mysql
+func (e *mysqlEngine) clientTool() string {
+	if e.autoClient && e.detectedFlavor() == flavorMariaDB {
+		if _, err := exec.LookPath("mariadb"); err == nil {
+			return "mariadb"
+		}
+	}
+	return e.mysqlPath
+}
+
+// toolVersion returns the version line of a dump tool, running it only once
+func (e *mysqlEngine) toolVersion(tool string) string {
+	e.mu.Lock()
+	version, ok := e.toolVersions[tool]
+	e.mu.Unlock()
+	if ok {
+		return version
+	}
+
+	version = toolVersion(tool, "MYSQLDUMP_PATH")
+	e.mu.Lock()
+	e.toolVersions[tool] = version
+	e.mu.Unlock()
+	return version
+}
+
+// dumpFlags returns mysqldumpFlags plus the options that depend on the dump tool and the
+// server. MariaDB's tools need none; MySQL's mysqldump leaves GTID_PURGED out of the dump
+// so that it restores into servers with GTIDs enabled, and since 8.0 must not query
+// column statistics, which MariaDB and MySQL before 8.0 don't have. With binlog set, the binary log position
+// is written into the dump as a comment.
+func (e *mysqlEngine) dumpFlags(tool string) []string {
+	flags := append([]string{}, mysqldumpFlags...)
+	version := e.toolVersion(tool)
+	mariadbTool := strings.Contains(version, "MariaDB")
+	major, minor, patch := parseToolVersion(version)
+	if e.binlog {
+		switch {
+		case mariadbTool:
+			flags = append(flags, "--master-data=2", "--gtid")
+		case major > 8 || (major == 8 && (minor > 0 || patch >= 26)):
+			// --master-data was renamed in 8.0.26 and is deprecated since
+			flags = append(flags, "--source-data=2")
+		default:
+			flags = append(flags, "--master-data=2")
+		}
+	}
+	if version == "" || mariadbTool {
+		return flags
+	}
+	if major > 5 || (major == 5 && minor >= 6) {
+		flags = append(flags, "--set-gtid-purged=OFF")
+	}
+	if major >= 8 && e.lacksColumnStatistics() {
+		flags = append(flags, "--column-statistics=0")
+	}
+	return flags
+}
+
+// parseToolVersion returns the MySQL or MariaDB release (major, minor, patch) a tool's
+// version line names
+func parseToolVersion(version string) (int, int, int) {
+	match := toolVersionPattern.FindAllStringSubmatch(version, -1)
+	if match == nil {
+		return 0, 0, 0
+	}
+	// "Distrib" comes after "Ver" and names the release when present
+	last := match[len(match)-1]
+	major, _ := strconv.Atoi(last[1])
+	minor, _ := strconv.Atoi(last[2])
+	patch, _ := strconv.Atoi(last[3])
+	return major, minor, patch
+}
+
+func (e *mysqlEngine) DumperInfo() domain.DumperInfo {
+	if e.native {
+		// The built-in dumper's version is the tool version of the manifest
+		return domain.DumperInfo{Tool: dumpMethodNative}
+	}
+	tool := e.dumpTool()
+	return domain.DumperInfo{
+		Tool:    filepath.Base(tool),
+		Version: e.toolVersion(tool),
+		Flags:   e.dumpFlags(tool),
+	}
+}
+
+// clientArgs returns the connection options shared by mysqldump and mysql
+func (e *mysqlEngine) clientArgs(ep Endpoint) []string {
+	return []string{
+		fmt.Sprintf("--host=%s", ep.Host),
+		fmt.Sprintf("--port=%d", ep.Port),
+		fmt.Sprintf("--user=%s", ep.User),
+		fmt.Sprintf("--password=%s", ep.Password),
+	}
+}
+
+func (e *mysqlEngine) Dump(ep Endpoint, dbName string, stdout io.Writer, stderr io.Writer) error {
+	if e.native {
+		return e.dumpNative(ep, dbName, stdout, stderr)
+	}
+
+	// The tool and its flags depend on the server
+	if _, _, err := e.server(ep); err != nil {
+		return err
+	}
+	tool := e.dumpTool()
+	mysqldump, err := resolveTool(tool, "MYSQLDUMP_PATH")
+	if err != nil {
+		return err
+	}
+
+	args := append(e.clientArgs(ep), e.dumpFlags(tool)...)
+	cmd := exec.Command(mysqldump, append(args, dbName)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", filepath.Base(tool), err)
+	}
+	return nil
+}
+
+func (e *mysqlEngine) Restore(ep Endpoint, dbName string, r io.Reader) error {
+	return e.restore(ep, []string{dbName}, r)
+}
+
+// restore replays SQL read from r with the mysql client; args follow the connection options
+func (e *mysqlEngine) restore(ep Endpoint, args []string, r io.Reader) error {
+	if _, _, err := e.server(ep); err != nil {
+		return err
+	}
+	tool := e.clientTool()
+	mysqlClient, err := resolveTool(tool, "MYSQL_PATH")
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(mysqlClient, append(e.clientArgs(ep), args...)...)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s restore failed: %w", filepath.Base(tool), err)
+	}
+	return nil
+}
+
+func (e *mysqlEngine) IsDatabaseEmpty(ep Endpoint, dbName string) (bool, error) {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?", dbName).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect database %s: %w", dbName, err)
+	}
+	return count == 0, nil
+}
+
+func (e *mysqlEngine) CreateDatabase(ep Endpoint, dbName string) error {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteIdentifier(dbName))); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", dbName, err)
+	}
+	return nil
+}
+
+func (e *mysqlEngine) DropDatabase(ep Endpoint, dbName string) error {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", quoteIdentifier(dbName))); err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", dbName, err)
+	}
+	return nil
+}
+
+func (e *mysqlEngine) TableRowCounts(ep Endpoint, dbName string) (map[string]int64, error) {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'", dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables of %s: %w", dbName, err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var count int64
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", quoteIdentifier(dbName), quoteIdentifier(table))
+		if err := db.QueryRow(query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s.%s: %w", dbName, table, err)
+		}
+		counts[table] = count
+	}
+	return counts, nil
+}
+
+func (e *mysqlEngine) QueryValue(ep Endpoint, dbName string, query string) (string, bool, error) {
+	db, err := e.open(ep, dbName)
+	if err != nil {
+		return "", false, err
+	}
+	defer db.Close()
+
+	var result sql.NullString
+	if err := db.QueryRow(query).Scan(&result); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return result.String, result.Valid, nil
+}
+
+// DumpsGlobals reports whether users are backed up; only MariaDB dumps them in logical form
+func (e *mysqlEngine) DumpsGlobals() bool {
+	return e.users && e.detectedFlavor() == flavorMariaDB
+}
+
+// DumpGlobals dumps users, roles and grants with mariadb-dump --system=users
+func (e *mysqlEngine) DumpGlobals(ep Endpoint, stdout io.Writer, stderr io.Writer) error {
+	if _, _, err := e.server(ep); err != nil {
+		return err
+	}
+	tool := e.dumpTool()
+	if !strings.Contains(e.toolVersion(tool), "MariaDB") {
+		return fmt.Errorf("backing up users needs mariadb-dump; set MYSQLDUMP_PATH to it or install it in PATH")
+	}
+	mariadbDump, err := resolveTool(tool, "MYSQLDUMP_PATH")
+	if err != nil {
+		return err
+	}
+
+	args := append(e.clientArgs(ep), mariadbDumpFlags...)
+	cmd := exec.Command(mariadbDump, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", filepath.Base(tool), err)
+	}
+	return nil
+}
+
+// RestoreGlobals replays a users dump; users that already exist are left as they are
+func (e *mysqlEngine) RestoreGlobals(ep Endpoint, r io.Reader) error {
+	return e.restore(ep, nil, r)
+}
+
+// quoteIdentifier quotes a MySQL identifier with backticks
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
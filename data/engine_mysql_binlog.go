@@ -0,0 +1,193 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// binlogFetchFlags make mysqlbinlog copy binary logs from the server unchanged
+var binlogFetchFlags = []string{"--read-from-remote-server", "--raw"}
+
+// binlogMagic starts every binary log file
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// binlogEventHeaderSize is the size of an event header in binary log format v4 (MySQL 5.0
+// and MariaDB on): timestamp (4 bytes), type (1), server ID (4), event size (4), next
+// position (4) and flags (2), little-endian
+const binlogEventHeaderSize = 19
+
+// binlogTool returns the binary log tool for the detected server: mariadb-binlog for
+// MariaDB when no path is configured and it is installed, mysqlbinlog otherwise
+func (e *mysqlEngine) binlogTool() string {
+	if e.autoBinlog && e.detectedFlavor() == flavorMariaDB {
+		if _, err := exec.LookPath("mariadb-binlog"); err == nil {
+			return "mariadb-binlog"
+		}
+	}
+	return e.mysqlbinlogPath
+}
+
+func (e *mysqlEngine) ListBinlogs(ep Endpoint) ([]string, error) {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := e.detectServer(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	defer rows.Close()
+
+	// MySQL 8 added an Encrypted column
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		var name string
+		values[0] = &name
+		for i := 1; i < len(values); i++ {
+			values[i] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, fmt.Errorf("failed to list binary logs: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	return names, nil
+}
+
+func (e *mysqlEngine) FlushBinlogs(ep Endpoint) error {
+	db, err := e.open(ep, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec("FLUSH BINARY LOGS"); err != nil {
+		return fmt.Errorf("failed to flush binary logs: %w", err)
+	}
+	return nil
+}
+
+func (e *mysqlEngine) BinlogDumperInfo() domain.DumperInfo {
+	tool := e.binlogTool()
+	return domain.DumperInfo{
+		Tool:    filepath.Base(tool),
+		Version: toolVersion(tool, "MYSQLBINLOG_PATH"),
+		Flags:   binlogFetchFlags,
+	}
+}
+
+// FetchBinlog copies a binary log with mysqlbinlog --read-from-remote-server --raw, which
+// writes it under its own name into the directory given as --result-file
+func (e *mysqlEngine) FetchBinlog(ep Endpoint, name string, dir string, stderr io.Writer) (string, error) {
+	tool := e.binlogTool()
+	mysqlbinlog, err := resolveTool(tool, "MYSQLBINLOG_PATH")
+	if err != nil {
+		return "", err
+	}
+
+	args := append(e.clientArgs(ep), binlogFetchFlags...)
+	args = append(args, "--result-file="+dir+string(os.PathSeparator), name)
+	cmd := exec.Command(mysqlbinlog, args...)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", filepath.Base(tool), err)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// binlogEventTimes returns the times of the first and last event in the size bytes of a
+// binary log file. Events without a timestamp, like the rotate event mysqlbinlog fakes at
+// the start, are skipped. Both times are zero when r isn't a binary log in format v4.
+func binlogEventTimes(r io.ReaderAt, size int64) (time.Time, time.Time, error) {
+	var first, last time.Time
+	magic := make([]byte, len(binlogMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil || !bytes.Equal(magic, binlogMagic) {
+		return first, last, nil
+	}
+	header := make([]byte, binlogEventHeaderSize)
+	for offset := int64(len(binlogMagic)); offset+binlogEventHeaderSize <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return first, last, fmt.Errorf("failed to read binary log event at %d: %w", offset, err)
+		}
+		if timestamp := binary.LittleEndian.Uint32(header[0:4]); timestamp != 0 {
+			last = time.Unix(int64(timestamp), 0)
+			if first.IsZero() {
+				first = last
+			}
+		}
+		eventSize := int64(binary.LittleEndian.Uint32(header[9:13]))
+		if eventSize < binlogEventHeaderSize {
+			return first, last, fmt.Errorf("invalid binary log event of %d bytes at %d", eventSize, offset)
+		}
+		offset += eventSize
+	}
+	return first, last, nil
+}
+
+// ReplayBinlogs pipes the events decoded by mysqlbinlog into the mysql client. Events of
+// other databases are skipped, and those of dbName are rewritten to targetDB when it
+// differs. MySQL's mysqlbinlog leaves out the GTIDs, which the server would otherwise
+// skip as already applied when restoring onto the server the logs came from.
+func (e *mysqlEngine) ReplayBinlogs(ep Endpoint, dbName string, targetDB string, files []string, start int64, stop time.Time) error {
+	if _, _, err := e.server(ep); err != nil {
+		return err
+	}
+	tool := e.binlogTool()
+	mysqlbinlog, err := resolveTool(tool, "MYSQLBINLOG_PATH")
+	if err != nil {
+		return err
+	}
+
+	// mysqlbinlog stops before the first event at or after --stop-datetime, which it
+	// reads in local time
+	args := []string{
+		fmt.Sprintf("--start-position=%d", start),
+		"--stop-datetime=" + stop.Add(time.Second).Local().Format("2006-01-02 15:04:05"),
+	}
+	if !strings.Contains(toolVersion(tool, "MYSQLBINLOG_PATH"), "MariaDB") {
+		args = append(args, "--skip-gtids")
+	}
+	// --database applies to the rewritten name
+	if targetDB != dbName {
+		args = append(args, fmt.Sprintf("--rewrite-db=%s->%s", dbName, targetDB))
+	}
+	args = append(args, "--database="+targetDB)
+	cmd := exec.Command(mysqlbinlog, append(args, files...)...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", filepath.Base(tool), err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", filepath.Base(tool), err)
+	}
+
+	restoreErr := e.restore(ep, []string{targetDB}, stdout)
+	// Let mysqlbinlog finish if the client stopped reading early
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w", filepath.Base(tool), err)
+	}
+	return restoreErr
+}
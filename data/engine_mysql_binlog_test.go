@@ -0,0 +1,52 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// binlogEvent returns an event with the given timestamp and a body of n bytes
+func binlogEvent(timestamp uint32, n int) []byte {
+	event := make([]byte, binlogEventHeaderSize+n)
+	binary.LittleEndian.PutUint32(event[0:4], timestamp)
+	binary.LittleEndian.PutUint32(event[9:13], uint32(len(event)))
+	return event
+}
+
+func TestBinlogEventTimes(t *testing.T) {
+	var binlog bytes.Buffer
+	binlog.Write(binlogMagic)
+	// The rotate event mysqlbinlog writes first has no timestamp
+	binlog.Write(binlogEvent(0, 20))
+	binlog.Write(binlogEvent(1792134000, 100))
+	binlog.Write(binlogEvent(1792134060, 0))
+	binlog.Write(binlogEvent(1792137600, 31))
+
+	first, last, err := binlogEventTimes(bytes.NewReader(binlog.Bytes()), int64(binlog.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Equal(time.Unix(1792134000, 0)) || !last.Equal(time.Unix(1792137600, 0)) {
+		t.Errorf("got %v and %v", first, last)
+	}
+
+	// A truncated last event is ignored
+	truncated := binlog.Bytes()[:binlog.Len()-10]
+	if _, last, err := binlogEventTimes(bytes.NewReader(truncated), int64(len(truncated))); err != nil || !last.Equal(time.Unix(1792137600, 0)) {
+		t.Errorf("truncated: got %v, %v", last, err)
+	}
+
+	corrupt := append(append([]byte{}, binlogMagic...), binlogEvent(1792134000, 0)...)
+	binary.LittleEndian.PutUint32(corrupt[len(binlogMagic)+9:], 3)
+	if _, _, err := binlogEventTimes(bytes.NewReader(corrupt), int64(len(corrupt))); err == nil {
+		t.Error("an event smaller than its header was accepted")
+	}
+
+	notBinlog := []byte("-- MySQL dump\n")
+	first, last, err = binlogEventTimes(bytes.NewReader(notBinlog), int64(len(notBinlog)))
+	if err != nil || !first.IsZero() || !last.IsZero() {
+		t.Errorf("not a binary log: got %v, %v, %v", first, last, err)
+	}
+}
@@ -0,0 +1,616 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// Dump methods of the MySQL engine
+const (
+	dumpMethodMysqldump = "mysqldump"
+	dumpMethodNative    = "native"
+)
+
+// nativeMaxStatement bounds the size of an extended INSERT, like mysqldump's net_buffer_length
+const nativeMaxStatement = 1 << 20
+
+// nativeDumper writes a dump of one database in the format of mysqldump, so that it can be
+// restored with the mysql client. Everything is read over a single connection within one
+// consistent-snapshot transaction.
+type nativeDumper struct {
+	ctx    context.Context
+	conn   *sql.Conn
+	w      *bufio.Writer
+	stderr io.Writer
+	// binlog writes the binary log position of the snapshot into the dump
+	binlog bool
+}
+
+// dumpNative dumps dbName with the built-in dumper instead of mysqldump
+func (e *mysqlEngine) dumpNative(ep Endpoint, dbName string, stdout io.Writer, stderr io.Writer) error {
+	db, err := e.open(ep, dbName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MySQL: %w", err)
+	}
+	defer conn.Close()
+
+	d := &nativeDumper{ctx: ctx, conn: conn, w: bufio.NewWriterSize(stdout, 64<<10), stderr: stderr, binlog: e.binlog}
+	if err := d.dump(dbName); err != nil {
+		return fmt.Errorf("native dump failed: %w", err)
+	}
+	return nil
+}
+
+// dump writes the tables with their data, triggers, routines and views of dbName
+func (d *nativeDumper) dump(dbName string) error {
+	// The binary log position must match the snapshot, so like mysqldump --source-data the
+	// snapshot is started while writes are blocked by a global read lock
+	if d.binlog {
+		if _, err := d.conn.ExecContext(d.ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+			return fmt.Errorf("failed to lock tables to read the binary log position: %w", err)
+		}
+	}
+	// TIMESTAMP values are read and written in UTC, as mysqldump does
+	for _, stmt := range []string{
+		"SET NAMES utf8mb4",
+		"SET time_zone = '+00:00'",
+		"SET SESSION sql_quote_show_create = 1",
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION /*!40100 WITH CONSISTENT SNAPSHOT */",
+	} {
+		if _, err := d.conn.ExecContext(d.ctx, stmt); err != nil {
+			return fmt.Errorf("failed to start snapshot: %w", err)
+		}
+	}
+	defer d.conn.ExecContext(d.ctx, "ROLLBACK")
+
+	var position *domain.BinlogPosition
+	var gtidVariable string
+	if d.binlog {
+		var err error
+		position, gtidVariable, err = d.binlogPosition()
+		if _, unlockErr := d.conn.ExecContext(d.ctx, "UNLOCK TABLES"); err == nil && unlockErr != nil {
+			err = fmt.Errorf("failed to unlock tables: %w", unlockErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var version string
+	if err := d.conn.QueryRowContext(d.ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return fmt.Errorf("failed to query server version: %w", err)
+	}
+	tables, views, err := d.listTables()
+	if err != nil {
+		return err
+	}
+
+	d.writeHeader(dbName, version)
+	if position != nil {
+		d.writePosition(position, gtidVariable)
+	}
+	for _, table := range tables {
+		if err := d.dumpTable(table); err != nil {
+			return err
+		}
+	}
+	// Views may select from views that come later, so every view is first created as a
+	// stand-in with the same columns and replaced at the end
+	for _, view := range views {
+		if err := d.dumpViewStandIn(view); err != nil {
+			return err
+		}
+	}
+	if err := d.dumpTriggers(); err != nil {
+		return err
+	}
+	if err := d.dumpRoutines(dbName); err != nil {
+		return err
+	}
+	for _, view := range views {
+		if err := d.dumpView(view); err != nil {
+			return err
+		}
+	}
+	d.writeFooter()
+	return d.w.Flush()
+}
+
+// nativeTable is a table to dump; MariaDB sequences have a position instead of rows
+type nativeTable struct {
+	name     string
+	sequence bool
+}
+
+// listTables returns the tables and views of the current database in name order
+func (d *nativeDumper) listTables() ([]nativeTable, []string, error) {
+	rows, err := d.conn.QueryContext(d.ctx, "SHOW FULL TABLES")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []nativeTable
+	var views []string
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		switch tableType {
+		case "VIEW":
+			views = append(views, name)
+		case "SEQUENCE":
+			tables = append(tables, nativeTable{name: name, sequence: true})
+		default:
+			// BASE TABLE, and SYSTEM VERSIONED on MariaDB
+			tables = append(tables, nativeTable{name: name})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	sort.Strings(views)
+	return tables, views, nil
+}
+
+// showCreate runs a SHOW CREATE statement and returns the columns of its row by name
+func (d *nativeDumper) showCreate(query string) (map[string]sql.NullString, error) {
+	rows, err := d.conn.QueryContext(d.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s returned no rows", query)
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		result[column] = values[i]
+	}
+	return result, nil
+}
+
+func (d *nativeDumper) writeHeader(dbName string, version string) {
+	fmt.Fprintf(d.w, "-- db-backup native dump\n--\n-- Database: %s\n", dbName)
+	fmt.Fprintf(d.w, "-- ------------------------------------------------------\n-- Server version\t%s\n\n", version)
+	d.w.WriteString(`/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!50503 SET NAMES utf8mb4 */;
+/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+
+`)
+}
+
+func (d *nativeDumper) writeFooter() {
+	d.w.WriteString(`/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
+
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+
+`)
+	fmt.Fprintf(d.w, "-- Dump completed on %s\n", time.Now().UTC().Format("2006-01-02 15:04:05"))
+}
+
+// binlogPosition returns the current binary log position with the server's GTID state,
+// and the variable the GTID state is restored with on this flavor
+func (d *nativeDumper) binlogPosition() (*domain.BinlogPosition, string, error) {
+	rows, err := d.conn.QueryContext(d.ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		// MySQL 8.4 only knows the new name
+		rows, err = d.conn.QueryContext(d.ctx, "SHOW BINARY LOG STATUS")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query binary log position: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query binary log position: %w", err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, "", fmt.Errorf("failed to query binary log position: %w", err)
+		}
+		return nil, "", fmt.Errorf("binary logging is not enabled on the server")
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, "", fmt.Errorf("failed to query binary log position: %w", err)
+	}
+	rows.Close()
+
+	position := &domain.BinlogPosition{File: values[0].String}
+	position.Position, _ = strconv.ParseInt(values[1].String, 10, 64)
+	for i, column := range columns {
+		if column == "Executed_Gtid_Set" {
+			// MySQL breaks long GTID sets over several lines
+			position.GTIDSet = strings.ReplaceAll(values[i].String, "\n", "")
+			return position, "@@GLOBAL.GTID_PURGED", nil
+		}
+	}
+	// MariaDB reports its GTID state in a variable instead
+	var gtid sql.NullString
+	if d.conn.QueryRowContext(d.ctx, "SELECT @@GLOBAL.gtid_binlog_pos").Scan(&gtid) == nil {
+		position.GTIDSet = gtid.String
+	}
+	return position, "GLOBAL gtid_slave_pos", nil
+}
+
+// writePosition writes the binary log position as comments in the format of mysqldump
+func (d *nativeDumper) writePosition(position *domain.BinlogPosition, gtidVariable string) {
+	d.writeSection("Position to start replication or point-in-time recovery from")
+	fmt.Fprintf(d.w, "-- CHANGE MASTER TO MASTER_LOG_FILE='%s', MASTER_LOG_POS=%d;\n", position.File, position.Position)
+	if position.GTIDSet != "" {
+		fmt.Fprintf(d.w, "-- SET %s='%s';\n", gtidVariable, position.GTIDSet)
+	}
+	d.w.WriteString("\n")
+}
+
+// writeSection writes a mysqldump-style comment block
+func (d *nativeDumper) writeSection(title string) {
+	fmt.Fprintf(d.w, "--\n-- %s\n--\n\n", title)
+}
+
+// dumpTable writes the structure and rows of a table, or the position of a sequence
+func (d *nativeDumper) dumpTable(t nativeTable) error {
+	table := t.name
+	create, err := d.showCreate("SHOW CREATE TABLE " + quoteIdentifier(table))
+	if err != nil {
+		return fmt.Errorf("failed to read structure of %s: %w", table, err)
+	}
+	d.writeSection(fmt.Sprintf("Table structure for table %s", quoteIdentifier(table)))
+	fmt.Fprintf(d.w, "DROP TABLE IF EXISTS %s;\n%s;\n\n", quoteIdentifier(table), create["Create Table"].String)
+	if t.sequence {
+		var next string
+		if err := d.conn.QueryRowContext(d.ctx, "SELECT next_not_cached_value FROM "+quoteIdentifier(table)).Scan(&next); err != nil {
+			return fmt.Errorf("failed to read position of sequence %s: %w", table, err)
+		}
+		fmt.Fprintf(d.w, "SELECT SETVAL(%s, %s, 0);\n\n", quoteIdentifier(table), next)
+		return nil
+	}
+
+	columns, complete, err := d.insertColumns(table)
+	if err != nil {
+		return err
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	rows, err := d.conn.QueryContext(d.ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ","), quoteIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("failed to read rows of %s: %w", table, err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("failed to read rows of %s: %w", table, err)
+	}
+
+	d.writeSection(fmt.Sprintf("Dumping data for table %s", quoteIdentifier(table)))
+	fmt.Fprintf(d.w, "LOCK TABLES %s WRITE;\n/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", quoteIdentifier(table), quoteIdentifier(table))
+
+	insert := "INSERT INTO " + quoteIdentifier(table) + " VALUES "
+	if complete {
+		// Generated and invisible columns need a column list
+		insert = "INSERT INTO " + quoteIdentifier(table) + " (" + strings.Join(quoted, ",") + ") VALUES "
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var stmt []byte
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to read rows of %s: %w", table, err)
+		}
+		if len(stmt) == 0 {
+			stmt = append(stmt, insert...)
+		} else {
+			stmt = append(stmt, ',')
+		}
+		stmt = append(stmt, '(')
+		for i, value := range values {
+			if i > 0 {
+				stmt = append(stmt, ',')
+			}
+			stmt = appendSQLValue(stmt, value, types[i].DatabaseTypeName())
+		}
+		stmt = append(stmt, ')')
+		if len(stmt) >= nativeMaxStatement {
+			// Stop reading the table as soon as the dump can't be written
+			if _, err := d.w.Write(append(stmt, ";\n"...)); err != nil {
+				return fmt.Errorf("failed to write rows of %s: %w", table, err)
+			}
+			stmt = stmt[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows of %s: %w", table, err)
+	}
+	if len(stmt) > 0 {
+		if _, err := d.w.Write(append(stmt, ";\n"...)); err != nil {
+			return fmt.Errorf("failed to write rows of %s: %w", table, err)
+		}
+	}
+
+	fmt.Fprintf(d.w, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\nUNLOCK TABLES;\n\n", quoteIdentifier(table))
+	// Surface write errors before reading the next table
+	return d.w.Flush()
+}
+
+// insertColumns returns the columns of a table that are dumped, leaving out generated
+// columns. complete is set when the INSERT must name them, because columns were left
+// out or are invisible.
+func (d *nativeDumper) insertColumns(table string) ([]string, bool, error) {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	complete := false
+	for rows.Next() {
+		var name, extra string
+		if err := rows.Scan(&name, &extra); err != nil {
+			return nil, false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		if isGeneratedColumn(extra) {
+			complete = true
+			continue
+		}
+		if strings.Contains(strings.ToUpper(extra), "INVISIBLE") {
+			complete = true
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, false, fmt.Errorf("failed to read columns of %s: no columns found", table)
+	}
+	return columns, complete, nil
+}
+
+// isGeneratedColumn reports whether the EXTRA of a column in information_schema.COLUMNS
+// marks it as generated: "VIRTUAL GENERATED" or "STORED GENERATED", and "VIRTUAL" or
+// "PERSISTENT" on older MariaDB. MySQL's "DEFAULT_GENERATED" only marks a column with an
+// expression default such as CURRENT_TIMESTAMP, whose values are dumped.
+func isGeneratedColumn(extra string) bool {
+	for _, word := range strings.Fields(strings.ToUpper(extra)) {
+		switch word {
+		case "VIRTUAL", "STORED", "PERSISTENT":
+			return true
+		}
+	}
+	return false
+}
+
+// appendSQLValue appends a value read with the text protocol as an SQL literal. Numbers
+// are written as they are, binary strings in hex and other values as quoted strings.
+func appendSQLValue(buf []byte, value sql.RawBytes, typeName string) []byte {
+	if value == nil {
+		return append(buf, "NULL"...)
+	}
+	switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return append(buf, value...)
+	case "BIT", "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "GEOMETRY":
+		if len(value) == 0 {
+			return append(buf, "''"...)
+		}
+		return hex.AppendEncode(append(buf, "0x"...), value)
+	}
+	return appendQuoted(buf, value)
+}
+
+// appendQuoted appends a single-quoted string, escaped like mysql_real_escape_string
+func appendQuoted(buf []byte, value []byte) []byte {
+	buf = append(buf, '\'')
+	for _, b := range value {
+		switch b {
+		case 0:
+			buf = append(buf, '\\', '0')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case 0x1a:
+			buf = append(buf, '\\', 'Z')
+		case '\\', '\'', '"':
+			buf = append(buf, '\\', b)
+		default:
+			buf = append(buf, b)
+		}
+	}
+	return append(buf, '\'')
+}
+
+// dumpViewStandIn creates a view with the columns of view that selects constants
+func (d *nativeDumper) dumpViewStandIn(view string) error {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", view)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of view %s: %w", view, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to read columns of view %s: %w", view, err)
+		}
+		columns = append(columns, "1 AS "+quoteIdentifier(name))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of view %s: %w", view, err)
+	}
+	if len(columns) == 0 {
+		columns = []string{"1"}
+	}
+
+	d.writeSection(fmt.Sprintf("Temporary view structure for view %s", quoteIdentifier(view)))
+	fmt.Fprintf(d.w, "DROP TABLE IF EXISTS %s;\nDROP VIEW IF EXISTS %s;\nCREATE VIEW %s AS SELECT %s;\n\n",
+		quoteIdentifier(view), quoteIdentifier(view), quoteIdentifier(view), strings.Join(columns, ", "))
+	return nil
+}
+
+// dumpView replaces the stand-in of a view with its definition
+func (d *nativeDumper) dumpView(view string) error {
+	create, err := d.showCreate("SHOW CREATE VIEW " + quoteIdentifier(view))
+	if err != nil {
+		return fmt.Errorf("failed to read definition of view %s: %w", view, err)
+	}
+	d.writeSection(fmt.Sprintf("Final view structure for view %s", quoteIdentifier(view)))
+	fmt.Fprintf(d.w, "DROP VIEW IF EXISTS %s;\n%s;\n\n", quoteIdentifier(view), create["Create View"].String)
+	return nil
+}
+
+// dumpTriggers writes the triggers of the current database. They are created after all
+// data was inserted, so that restoring doesn't fire them.
+func (d *nativeDumper) dumpTriggers() error {
+	names, err := d.queryNames("SELECT TRIGGER_NAME FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = DATABASE() ORDER BY EVENT_OBJECT_TABLE, ACTION_ORDER")
+	if err != nil {
+		return fmt.Errorf("failed to list triggers: %w", err)
+	}
+	for _, name := range names {
+		create, err := d.showCreate("SHOW CREATE TRIGGER " + quoteIdentifier(name))
+		if err != nil {
+			return fmt.Errorf("failed to read definition of trigger %s: %w", name, err)
+		}
+		d.writeSection(fmt.Sprintf("Trigger %s", quoteIdentifier(name)))
+		d.writeDelimited(create["sql_mode"].String, create["SQL Original Statement"].String)
+	}
+	return nil
+}
+
+// dumpRoutines writes the stored procedures and functions of the current database
+func (d *nativeDumper) dumpRoutines(dbName string) error {
+	rows, err := d.conn.QueryContext(d.ctx, "SELECT ROUTINE_TYPE, ROUTINE_NAME FROM information_schema.ROUTINES "+
+		"WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_TYPE IN ('PROCEDURE', 'FUNCTION') ORDER BY ROUTINE_TYPE, ROUTINE_NAME")
+	if err != nil {
+		return fmt.Errorf("failed to list routines: %w", err)
+	}
+	type routine struct{ kind, name string }
+	var routines []routine
+	for rows.Next() {
+		var r routine
+		if err := rows.Scan(&r.kind, &r.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list routines: %w", err)
+		}
+		routines = append(routines, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list routines: %w", err)
+	}
+	if len(routines) == 0 {
+		return nil
+	}
+
+	d.writeSection(fmt.Sprintf("Dumping routines for database '%s'", dbName))
+	for _, r := range routines {
+		create, err := d.showCreate(fmt.Sprintf("SHOW CREATE %s %s", r.kind, quoteIdentifier(r.name)))
+		if err != nil {
+			return fmt.Errorf("failed to read definition of %s %s: %w", strings.ToLower(r.kind), r.name, err)
+		}
+		// The definition is NULL without the privileges to read it
+		column := "Create Procedure"
+		if r.kind == "FUNCTION" {
+			column = "Create Function"
+		}
+		definition := create[column]
+		if !definition.Valid {
+			fmt.Fprintf(d.stderr, "Warning: skipping %s %s: insufficient privileges to read its definition\n", strings.ToLower(r.kind), r.name)
+			continue
+		}
+		fmt.Fprintf(d.w, "DROP %s IF EXISTS %s;\n", r.kind, quoteIdentifier(r.name))
+		d.writeDelimited(create["sql_mode"].String, definition.String)
+	}
+	return nil
+}
+
+// writeDelimited writes a trigger or routine definition, which contains semicolons, under
+// its own delimiter and SQL mode
+func (d *nativeDumper) writeDelimited(sqlMode string, definition string) {
+	fmt.Fprintf(d.w, "/*!50003 SET @saved_sql_mode = @@sql_mode */ ;\n/*!50003 SET sql_mode = %s */ ;\n", string(appendQuoted(nil, []byte(sqlMode))))
+	fmt.Fprintf(d.w, "DELIMITER ;;\n%s ;;\nDELIMITER ;\n/*!50003 SET sql_mode = @saved_sql_mode */ ;\n\n", definition)
+}
+
+// queryNames runs a query returning a single column of names
+func (d *nativeDumper) queryNames(query string) ([]string, error) {
+	rows, err := d.conn.QueryContext(d.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestIsGeneratedColumn(t *testing.T) {
+	tests := []struct {
+		extra string
+		want  bool
+	}{
+		{"", false},
+		{"auto_increment", false},
+		{"DEFAULT_GENERATED", false},
+		{"DEFAULT_GENERATED on update CURRENT_TIMESTAMP", false},
+		{"on update CURRENT_TIMESTAMP", false},
+		{"INVISIBLE", false},
+		{"VIRTUAL GENERATED", true},
+		{"STORED GENERATED", true},
+		{"VIRTUAL GENERATED INVISIBLE", true},
+		{"PERSISTENT", true},
+		{"VIRTUAL", true},
+		{"stored generated", true},
+	}
+	for _, tt := range tests {
+		if got := isGeneratedColumn(tt.extra); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.extra, got, tt.want)
+		}
+	}
+}
+
+func TestAppendSQLValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    sql.RawBytes
+		typeName string
+		want     string
+	}{
+		{"null", nil, "VARCHAR", "NULL"},
+		{"null number", nil, "INT", "NULL"},
+		{"null binary", nil, "BLOB", "NULL"},
+		{"empty string", sql.RawBytes{}, "VARCHAR", "''"},
+		{"int", sql.RawBytes("-42"), "INT", "-42"},
+		{"unsigned bigint", sql.RawBytes("18446744073709551615"), "UNSIGNED BIGINT", "18446744073709551615"},
+		{"decimal", sql.RawBytes("3.14"), "DECIMAL", "3.14"},
+		{"year", sql.RawBytes("2026"), "YEAR", "2026"},
+		{"binary", sql.RawBytes{0x00, 0xff, '\'', 0x1a}, "VARBINARY", "0x00ff271a"},
+		{"blob", sql.RawBytes("abc"), "BLOB", "0x616263"},
+		{"bit", sql.RawBytes{0x05}, "BIT", "0x05"},
+		{"empty binary", sql.RawBytes{}, "BLOB", "''"},
+		{"date", sql.RawBytes("2026-10-16 03:00:00"), "DATETIME", "'2026-10-16 03:00:00'"},
+		{"json", sql.RawBytes(`{"a": "b"}`), "JSON", `'{\"a\": \"b\"}'`},
+		{"text with quotes", sql.RawBytes("O'Brien"), "TEXT", `'O\'Brien'`},
+	}
+	for _, tt := range tests {
+		if got := string(appendSQLValue(nil, tt.value, tt.typeName)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendQuoted(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `''`},
+		{"plain", `'plain'`},
+		{"it's", `'it\'s'`},
+		{`say "hi"`, `'say \"hi\"'`},
+		{`C:\path`, `'C:\\path'`},
+		{"a\x00b", `'a\0b'`},
+		{"line\nbreak\r\n", `'line\nbreak\r\n'`},
+		{"ctrl-z\x1a", `'ctrl-z\Z'`},
+		{"tab\tstays", "'tab\tstays'"},
+		{"grüße ✓", `'grüße ✓'`},
+		{`\'`, `'\\\''`},
+	}
+	for _, tt := range tests {
+		if got := string(appendQuoted([]byte("x,"), []byte(tt.value))); got != "x,"+tt.want {
+			t.Errorf("%q: got %s, want x,%s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// physicalBackupFlags make xtrabackup and mariabackup stream a backup to stdout
+var physicalBackupFlags = []string{"--backup", "--stream=xbstream"}
+
+// PhysicalTools picks xtrabackup and xbstream for MySQL, and mariabackup (mariadb-backup
+// from MariaDB 11 on) and mbstream for MariaDB. A configured tool is used for any server,
+// with its extractor looked up next to it.
+func (e *mysqlEngine) PhysicalTools(flavor string, version string) (string, string) {
+	backup, extract := "xtrabackup", "xbstream"
+	if flavor == flavorMariaDB {
+		backup, extract = "mariabackup", "mbstream"
+		if major, _ := strconv.Atoi(strings.SplitN(version, ".", 2)[0]); major >= 11 {
+			backup = "mariadb-backup"
+		}
+	}
+	if e.physicalPath == "" {
+		return backup, extract
+	}
+
+	backup, extract = e.physicalPath, "xbstream"
+	if strings.Contains(filepath.Base(backup), "maria") {
+		extract = "mbstream"
+	}
+	if filepath.IsAbs(backup) {
+		extract = filepath.Join(filepath.Dir(backup), extract)
+	}
+	return backup, extract
+}
+
+func (e *mysqlEngine) PhysicalBackupFlags() []string {
+	return physicalBackupFlags
+}
+
+func (e *mysqlEngine) PhysicalConnectionArgs(ep Endpoint) []string {
+	return e.clientArgs(ep)
+}
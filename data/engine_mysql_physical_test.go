@@ -0,0 +1,81 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func TestPhysicalTools(t *testing.T) {
+	tests := []struct {
+		name            string
+		physicalPath    string
+		flavor, version string
+		backup, extract string
+	}{
+		{"MySQL", "", flavorMySQL, "8.0.36", "xtrabackup", "xbstream"},
+		{"MariaDB 10", "", flavorMariaDB, "10.11.6-MariaDB-log", "mariabackup", "mbstream"},
+		{"MariaDB 11", "", flavorMariaDB, "11.4.2-MariaDB", "mariadb-backup", "mbstream"},
+		{"configured xtrabackup", "/opt/xtrabackup/bin/xtrabackup", flavorMySQL, "8.0.36",
+			"/opt/xtrabackup/bin/xtrabackup", "/opt/xtrabackup/bin/xbstream"},
+		{"configured mariabackup", "/usr/local/bin/mariabackup", flavorMySQL, "8.0.36",
+			"/usr/local/bin/mariabackup", "/usr/local/bin/mbstream"},
+		{"configured relative tool", "xtrabackup-8.0", flavorMariaDB, "10.11.6-MariaDB",
+			"xtrabackup-8.0", "xbstream"},
+	}
+	for _, tt := range tests {
+		e := &mysqlEngine{physicalPath: tt.physicalPath}
+		backup, extract := e.PhysicalTools(tt.flavor, tt.version)
+		if backup != tt.backup || extract != tt.extract {
+			t.Errorf("%s: PhysicalTools = %s, %s; want %s, %s", tt.name, backup, extract, tt.backup, tt.extract)
+		}
+	}
+}
+
+func TestBackupTypeForFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		backupType string
+		backup     bool
+	}{
+		{"_physical-20261016030000.xbstream", domain.BackupTypePhysical, true},
+		{"_physical-20261016030000.xbstream.zst.age", domain.BackupTypePhysical, true},
+		{"shop-20261016030000.sql.gz", domain.BackupTypeLogical, true},
+		{"_physical-20261016030000.xbstream.manifest.json", domain.BackupTypeLogical, false},
+	}
+	for _, tt := range tests {
+		if got := BackupTypeForFile(tt.name); got != tt.backupType {
+			t.Errorf("BackupTypeForFile(%s) = %s, want %s", tt.name, got, tt.backupType)
+		}
+		if got := isBackupFile(tt.name); got != tt.backup {
+			t.Errorf("isBackupFile(%s) = %v, want %v", tt.name, got, tt.backup)
+		}
+	}
+
+	if _, ok := domain.ParseBackupTime("_physical/_physical-20261016030000.xbstream.zst"); !ok {
+		t.Error("ParseBackupTime should read the timestamp of physical backups")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"xtrabackup":          "'xtrabackup'",
+		"--password=it's $ok": `'--password=it'\''s $ok'`,
+		"":                    "''",
+	}
+	for arg, want := range tests {
+		if got := shellQuote(arg); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestDumpStatsWriterSizeOnly(t *testing.T) {
+	w := newDumpStatsWriter()
+	w.sizeOnly = true
+	w.Write([]byte("CREATE TABLE `t` (\n"))
+	w.Write([]byte("INSERT INTO `t` VALUES (1);\n"))
+	if w.stats.Size != 47 || len(w.stats.Tables) != 0 {
+		t.Errorf("size-only stats = %+v", w.stats)
+	}
+}
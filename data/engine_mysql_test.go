@@ -0,0 +1,104 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseToolVersion(t *testing.T) {
+	tests := []struct {
+		version             string
+		major, minor, patch int
+	}{
+		{"mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)", 8, 0, 36},
+		{"mysqldump  Ver 8.4.0 for Linux on x86_64 (MySQL Community Server - GPL)", 8, 4, 0},
+		{"mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)", 5, 7, 44},
+		{"mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB, for debian-linux-gnu (x86_64)", 10, 11, 6},
+		{"", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		major, minor, patch := parseToolVersion(tt.version)
+		if major != tt.major || minor != tt.minor || patch != tt.patch {
+			t.Errorf("parseToolVersion(%q) = %d.%d.%d, want %d.%d.%d", tt.version, major, minor, patch, tt.major, tt.minor, tt.patch)
+		}
+	}
+}
+
+func TestDumpFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		flavor  string
+		server  string
+		want    string
+	}{
+		{"mysqldump 8 against MySQL 8", "mysqldump  Ver 8.0.36 for Linux on x86_64", flavorMySQL, "8.0.36",
+			"--set-gtid-purged=OFF"},
+		{"mysqldump 8 against MySQL 5.7", "mysqldump  Ver 8.0.36 for Linux on x86_64", flavorMySQL, "5.7.44-log",
+			"--set-gtid-purged=OFF --column-statistics=0"},
+		{"mysqldump 8 against MariaDB", "mysqldump  Ver 8.0.36 for Linux on x86_64", flavorMariaDB, "10.11.6-MariaDB",
+			"--set-gtid-purged=OFF --column-statistics=0"},
+		{"mysqldump 8 before detection", "mysqldump  Ver 8.0.36 for Linux on x86_64", "", "",
+			"--set-gtid-purged=OFF"},
+		{"mysqldump 5.7", "mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)", flavorMySQL, "5.7.44",
+			"--set-gtid-purged=OFF"},
+		{"mysqldump 5.5 has no GTIDs", "mysqldump  Ver 10.13 Distrib 5.5.62, for Linux (x86_64)", flavorMySQL, "5.5.62",
+			""},
+		{"mariadb-dump", "mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB, for debian-linux-gnu (x86_64)", flavorMariaDB, "10.11.6-MariaDB",
+			""},
+		{"unknown version", "", flavorMySQL, "8.0.36", ""},
+	}
+	for _, tt := range tests {
+		e := &mysqlEngine{flavor: tt.flavor, version: tt.server, toolVersions: map[string]string{"mysqldump": tt.version}}
+		flags := e.dumpFlags("mysqldump")
+		want := strings.TrimSpace(strings.Join(mysqldumpFlags, " ") + " " + tt.want)
+		if got := strings.Join(flags, " "); got != want {
+			t.Errorf("%s: dumpFlags = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestMySQLEngineDumpsGlobals(t *testing.T) {
+	tests := []struct {
+		users  bool
+		flavor string
+		want   bool
+	}{
+		{true, flavorMariaDB, true},
+		{true, flavorMySQL, false},
+		{true, "", false},
+		{false, flavorMariaDB, false},
+	}
+	for _, tt := range tests {
+		e := &mysqlEngine{users: tt.users, flavor: tt.flavor}
+		if got := e.DumpsGlobals(); got != tt.want {
+			t.Errorf("users=%v flavor=%q: DumpsGlobals = %v, want %v", tt.users, tt.flavor, got, tt.want)
+		}
+	}
+}
+
+func TestDumpFlagsBinlogPosition(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)", "--master-data=2"},
+		{"mysqldump  Ver 8.0.25 for Linux on x86_64 (MySQL Community Server - GPL)", "--master-data=2"},
+		{"mysqldump  Ver 8.0.26 for Linux on x86_64 (MySQL Community Server - GPL)", "--source-data=2"},
+		{"mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)", "--source-data=2"},
+		{"mysqldump  Ver 8.4.0 for Linux on x86_64 (MySQL Community Server - GPL)", "--source-data=2"},
+		{"mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB, for debian-linux-gnu (x86_64)", "--master-data=2"},
+	}
+	for _, tt := range tests {
+		e := &mysqlEngine{mysqldumpPath: "mysqldump", binlog: true, toolVersions: map[string]string{"mysqldump": tt.version}}
+		var got []string
+		for _, flag := range e.dumpFlags("mysqldump") {
+			if flag == "--master-data=2" || flag == "--source-data=2" {
+				got = append(got, flag)
+			}
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%q: binlog position flags = %v, want %s", tt.version, got, tt.want)
+		}
+	}
+}
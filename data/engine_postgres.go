@@ -0,0 +1,300 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func init() {
+	RegisterEngine("postgres", newPostgresEngine)
+	RegisterEngine("postgresql", newPostgresEngine)
+}
+
+// pgDumpFlags are the dump options passed to pg_dump besides the connection settings
+var pgDumpFlags = []string{"--format=plain", "--no-password"}
+
+// pgMaintenanceDB is the database psql connects to for server-wide queries
+const pgMaintenanceDB = "postgres"
+
+// pgRecordSeparator separates the rows printed by psql
+const pgRecordSeparator = "\x1e"
+
+// pgNull is what psql prints for NULL, so that it can be told apart from an empty string
+const pgNull = "\x01NULL\x01"
+
+// postgresEngine backs up PostgreSQL with pg_dump and restores with psql. Queries also
+// run through psql, so no Postgres driver is linked into the binary.
+type postgresEngine struct {
+	pgDumpPath        string
+	pgDumpAllPath     string
+	psqlPath          string
+	globals           bool
+	includePostgresDB bool
+}
+
+func newPostgresEngine(cfg EngineConfig) (Engine, error) {
+	pgDump := firstNonEmpty(cfg.DumpPath, os.Getenv("PG_DUMP_PATH"), "pg_dump")
+	// pg_dumpall ships next to pg_dump
+	pgDumpAll := "pg_dumpall"
+	if filepath.IsAbs(pgDump) {
+		pgDumpAll = filepath.Join(filepath.Dir(pgDump), "pg_dumpall")
+	}
+	return &postgresEngine{
+		pgDumpPath:        pgDump,
+		pgDumpAllPath:     firstNonEmpty(cfg.DumpAllPath, os.Getenv("PG_DUMPALL_PATH"), pgDumpAll),
+		psqlPath:          firstNonEmpty(cfg.ClientPath, os.Getenv("PSQL_PATH"), "psql"),
+		globals:           cfg.Globals || envBool("PG_DUMP_GLOBALS"),
+		includePostgresDB: cfg.IncludePostgresDB || envBool("PG_INCLUDE_POSTGRES_DB"),
+	}, nil
+}
+
+func (e *postgresEngine) Name() string     { return "postgres" }
+func (e *postgresEngine) DefaultPort() int { return 5432 }
+
+func (e *postgresEngine) SystemDatabases() []string {
+	// Template databases are already left out by ListDatabases
+	excluded := []string{"template0", "template1"}
+	if !e.includePostgresDB {
+		excluded = append(excluded, pgMaintenanceDB)
+	}
+	return excluded
+}
+
+// command builds a pg_dump, pg_dumpall or psql command. The password is passed in the
+// environment so it doesn't show up in the process list.
+func (e *postgresEngine) command(tool string, ep Endpoint, args ...string) *exec.Cmd {
+	connArgs := []string{
+		fmt.Sprintf("--host=%s", ep.Host),
+		fmt.Sprintf("--port=%d", ep.Port),
+		fmt.Sprintf("--username=%s", ep.User),
+	}
+	cmd := exec.Command(tool, append(connArgs, args...)...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+ep.Password)
+	return cmd
+}
+
+// query runs an SQL statement with psql against dbName and returns the rows it printed
+func (e *postgresEngine) query(ep Endpoint, dbName string, query string) ([][]string, error) {
+	psql, err := resolveTool(e.psqlPath, "PSQL_PATH")
+	if err != nil {
+		return nil, err
+	}
+
+	// Unaligned output with NUL between fields and RS between rows can't be confused with
+	// the data; psql ends the last row with a newline
+	cmd := e.command(psql, ep, "--dbname="+dbName, "--no-psqlrc", "--no-password", "--quiet",
+		"--tuples-only", "--no-align", "--field-separator-zero", "--record-separator="+pgRecordSeparator,
+		"--pset=null="+pgNull, "--set=ON_ERROR_STOP=true", "--command="+query)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, fmt.Errorf("psql failed: %w", err)
+	}
+
+	output := strings.TrimSuffix(string(out), "\n")
+	if output == "" {
+		return nil, nil
+	}
+	var rows [][]string
+	for _, record := range strings.Split(output, pgRecordSeparator) {
+		rows = append(rows, strings.Split(record, "\x00"))
+	}
+	return rows, nil
+}
+
+func (e *postgresEngine) ListDatabases(ep Endpoint) ([]string, error) {
+	rows, err := e.query(ep, pgMaintenanceDB,
+		"SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row[0])
+	}
+	return names, nil
+}
+
+func (e *postgresEngine) ServerVersion(ep Endpoint) (string, string, error) {
+	rows, err := e.query(ep, pgMaintenanceDB, "SHOW server_version")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query server version: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", "", fmt.Errorf("failed to query server version: no result")
+	}
+	return "postgresql", rows[0][0], nil
+}
+
+func (e *postgresEngine) DumperInfo() domain.DumperInfo {
+	return domain.DumperInfo{
+		Tool:    "pg_dump",
+		Version: toolVersion(e.pgDumpPath, "PG_DUMP_PATH"),
+		Flags:   pgDumpFlags,
+	}
+}
+
+func (e *postgresEngine) Dump(ep Endpoint, dbName string, stdout io.Writer, stderr io.Writer) error {
+	pgDump, err := resolveTool(e.pgDumpPath, "PG_DUMP_PATH")
+	if err != nil {
+		return err
+	}
+
+	args := append(append([]string{}, pgDumpFlags...), "--dbname="+dbName)
+	cmd := e.command(pgDump, ep, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %w", err)
+	}
+	return nil
+}
+
+func (e *postgresEngine) Restore(ep Endpoint, dbName string, r io.Reader) error {
+	return e.restore(ep, dbName, r, true)
+}
+
+// restore replays SQL read from r with psql, optionally stopping at the first error
+func (e *postgresEngine) restore(ep Endpoint, dbName string, r io.Reader, stopOnError bool) error {
+	psql, err := resolveTool(e.psqlPath, "PSQL_PATH")
+	if err != nil {
+		return err
+	}
+
+	cmd := e.command(psql, ep, "--dbname="+dbName, "--no-psqlrc", "--no-password", "--quiet",
+		"--set=ON_ERROR_STOP="+strconv.FormatBool(stopOnError))
+	cmd.Stdin = r
+	cmd.Stdout = io.Discard
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql restore failed: %w", err)
+	}
+	return nil
+}
+
+// databaseExists reports whether dbName exists on the server
+func (e *postgresEngine) databaseExists(ep Endpoint, dbName string) (bool, error) {
+	rows, err := e.query(ep, pgMaintenanceDB, "SELECT 1 FROM pg_database WHERE datname = "+quoteLiteral(dbName))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect database %s: %w", dbName, err)
+	}
+	return len(rows) > 0, nil
+}
+
+func (e *postgresEngine) IsDatabaseEmpty(ep Endpoint, dbName string) (bool, error) {
+	exists, err := e.databaseExists(ep, dbName)
+	if err != nil || !exists {
+		return !exists, err
+	}
+	rows, err := e.query(ep, dbName, "SELECT count(*) FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema')")
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect database %s: %w", dbName, err)
+	}
+	return len(rows) > 0 && rows[0][0] == "0", nil
+}
+
+func (e *postgresEngine) CreateDatabase(ep Endpoint, dbName string) error {
+	exists, err := e.databaseExists(ep, dbName)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := e.query(ep, pgMaintenanceDB, "CREATE DATABASE "+quotePgIdentifier(dbName)); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", dbName, err)
+	}
+	return nil
+}
+
+func (e *postgresEngine) DropDatabase(ep Endpoint, dbName string) error {
+	if _, err := e.query(ep, pgMaintenanceDB, "DROP DATABASE IF EXISTS "+quotePgIdentifier(dbName)); err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", dbName, err)
+	}
+	return nil
+}
+
+// TableRowCounts names tables "schema.table", as the dump statistics do. Rows are
+// counted without those of child tables and partitions, which are dumped separately.
+func (e *postgresEngine) TableRowCounts(ep Endpoint, dbName string) (map[string]int64, error) {
+	tables, err := e.query(ep, dbName, "SELECT schemaname, tablename FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables of %s: %w", dbName, err)
+	}
+
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		name := table[0] + "." + table[1]
+		rows, err := e.query(ep, dbName, fmt.Sprintf("SELECT count(*) FROM ONLY %s.%s", quotePgIdentifier(table[0]), quotePgIdentifier(table[1])))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", name, err)
+		}
+		count, err := strconv.ParseInt(rows[0][0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", name, err)
+		}
+		counts[name] = count
+	}
+	return counts, nil
+}
+
+func (e *postgresEngine) QueryValue(ep Endpoint, dbName string, query string) (string, bool, error) {
+	rows, err := e.query(ep, dbName, query)
+	if err != nil {
+		return "", false, err
+	}
+	if len(rows) == 0 || rows[0][0] == pgNull {
+		return "", false, nil
+	}
+	// psql prints booleans as t and f
+	switch rows[0][0] {
+	case "t":
+		return "1", true, nil
+	case "f":
+		return "0", true, nil
+	}
+	return rows[0][0], true, nil
+}
+
+func (e *postgresEngine) DumpsGlobals() bool { return e.globals }
+
+// DumpGlobals dumps roles and tablespaces with pg_dumpall --globals-only
+func (e *postgresEngine) DumpGlobals(ep Endpoint, stdout io.Writer, stderr io.Writer) error {
+	pgDumpAll, err := resolveTool(e.pgDumpAllPath, "PG_DUMPALL_PATH")
+	if err != nil {
+		return err
+	}
+
+	cmd := e.command(pgDumpAll, ep, "--globals-only", "--no-password", "--database="+pgMaintenanceDB)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dumpall failed: %w", err)
+	}
+	return nil
+}
+
+// RestoreGlobals replays a globals dump. Roles that already exist are reported by
+// psql and skipped rather than aborting the restore.
+func (e *postgresEngine) RestoreGlobals(ep Endpoint, r io.Reader) error {
+	return e.restore(ep, pgMaintenanceDB, r, false)
+}
+
+// quotePgIdentifier quotes a PostgreSQL identifier with double quotes
+func quotePgIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a PostgreSQL string literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
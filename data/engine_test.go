@@ -0,0 +1,129 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewEngine(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		want  string
+		isErr bool
+	}{
+		{"", "", "mysql", false},
+		{"", "postgres", "postgres", false},
+		{"MySQL", "postgres", "mysql", false},
+		{"postgresql", "", "postgres", false},
+		{"oracle", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DB_ENGINE", tt.env)
+		engine, err := NewEngine(EngineConfig{Name: tt.name})
+		if tt.isErr {
+			if err == nil || !strings.Contains(err.Error(), "available: ") {
+				t.Errorf("NewEngine(%q) error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("NewEngine(%q): %v", tt.name, err)
+		}
+		if engine.Name() != tt.want {
+			t.Errorf("NewEngine(%q) with DB_ENGINE=%q = %s, want %s", tt.name, tt.env, engine.Name(), tt.want)
+		}
+	}
+}
+
+// newTestEngine creates an engine with factory and fails the test on errors
+func newTestEngine(t *testing.T, factory EngineFactory, cfg EngineConfig) Engine {
+	t.Helper()
+	engine, err := factory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestEngineConfigFallsBackToEnv(t *testing.T) {
+	t.Setenv("MYSQLDUMP_PATH", "/opt/mysql/bin/mysqldump")
+	t.Setenv("MYSQL_PATH", "")
+	t.Setenv("DUMP_METHOD", "")
+	mysql := newTestEngine(t, newMySQLEngine, EngineConfig{}).(*mysqlEngine)
+	if mysql.mysqldumpPath != "/opt/mysql/bin/mysqldump" || mysql.mysqlPath != "mysql" {
+		t.Errorf("mysql engine from .env: %+v", mysql)
+	}
+	mysql = newTestEngine(t, newMySQLEngine, EngineConfig{DumpPath: "/usr/bin/mysqldump"}).(*mysqlEngine)
+	if mysql.mysqldumpPath != "/usr/bin/mysqldump" {
+		t.Errorf("connection setting should win over .env: %s", mysql.mysqldumpPath)
+	}
+
+	t.Setenv("DUMP_METHOD", "native")
+	if mysql = newTestEngine(t, newMySQLEngine, EngineConfig{}).(*mysqlEngine); !mysql.native {
+		t.Error("DUMP_METHOD=native should select the native dumper")
+	}
+	if mysql = newTestEngine(t, newMySQLEngine, EngineConfig{DumpMethod: "mysqldump"}).(*mysqlEngine); mysql.native {
+		t.Error("connection dump method should win over .env")
+	}
+	if _, err := newMySQLEngine(EngineConfig{DumpMethod: "mydumper"}); err == nil {
+		t.Error("unknown dump method: expected an error")
+	}
+
+	t.Setenv("PG_DUMP_PATH", "/usr/lib/postgresql/16/bin/pg_dump")
+	t.Setenv("PG_DUMPALL_PATH", "")
+	t.Setenv("PSQL_PATH", "")
+	t.Setenv("PG_DUMP_GLOBALS", "true")
+	t.Setenv("PG_INCLUDE_POSTGRES_DB", "")
+	pg := newTestEngine(t, newPostgresEngine, EngineConfig{}).(*postgresEngine)
+	if pg.pgDumpPath != "/usr/lib/postgresql/16/bin/pg_dump" || pg.psqlPath != "psql" || !pg.globals || pg.includePostgresDB {
+		t.Errorf("postgres engine from .env: %+v", pg)
+	}
+	// pg_dumpall is looked up next to an absolute pg_dump
+	if pg.pgDumpAllPath != "/usr/lib/postgresql/16/bin/pg_dumpall" {
+		t.Errorf("pg_dumpall = %s", pg.pgDumpAllPath)
+	}
+
+	pg = newTestEngine(t, newPostgresEngine, EngineConfig{DumpPath: "pg_dump", IncludePostgresDB: true}).(*postgresEngine)
+	if pg.pgDumpAllPath != "pg_dumpall" {
+		t.Errorf("pg_dumpall for a relative pg_dump = %s", pg.pgDumpAllPath)
+	}
+	if excluded := strings.Join(pg.SystemDatabases(), ","); excluded != "template0,template1" {
+		t.Errorf("SystemDatabases with the postgres database included = %s", excluded)
+	}
+}
+
+func TestPostgresCommand(t *testing.T) {
+	pg := &postgresEngine{}
+	cmd := pg.command("/usr/bin/psql", Endpoint{Host: "127.0.0.1", Port: 15432, User: "backup", Password: "s3cret"}, "--dbname=shop")
+
+	if got := strings.Join(cmd.Args[1:], " "); got != "--host=127.0.0.1 --port=15432 --username=backup --dbname=shop" {
+		t.Errorf("args = %s", got)
+	}
+	// The password goes into the environment, never onto the command line
+	if strings.Contains(strings.Join(cmd.Args, " "), "s3cret") {
+		t.Errorf("password on the command line: %v", cmd.Args)
+	}
+	if env := cmd.Env[len(cmd.Env)-1]; env != "PGPASSWORD=s3cret" {
+		t.Errorf("last environment variable = %q, want PGPASSWORD", env)
+	}
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	tests := []struct {
+		quote func(string) string
+		in    string
+		want  string
+	}{
+		{quoteIdentifier, "orders", "`orders`"},
+		{quoteIdentifier, "we`ird", "`we``ird`"},
+		{quotePgIdentifier, "Orders", `"Orders"`},
+		{quotePgIdentifier, `we"ird`, `"we""ird"`},
+		{quoteLiteral, "O'Brien", "'O''Brien'"},
+	}
+	for _, tt := range tests {
+		if got := tt.quote(tt.in); got != tt.want {
+			t.Errorf("quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+// ManifestExtension is appended to a backup's key to name its manifest sidecar
+const ManifestExtension = ".manifest.json"
+
+// manifestKey returns the key of the manifest stored next to a backup
+func manifestKey(key string) string {
+	return key + ManifestExtension
+}
+
+// StoreManifest stores the manifest of a backup next to it
+func (sg *StorageGateway) StoreManifest(dbName string, fileName string, manifest *domain.Manifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	key := manifestKey(backupKey(dbName, fileName))
+	if err := sg.storage.Put(context.Background(), key, bytes.NewReader(append(content, '\n'))); err != nil {
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest of the backup with key ("<db>/<file>"). Backups
+// made before manifests were introduced have none and return an error.
+func (sg *StorageGateway) LoadManifest(key string) (*domain.Manifest, error) {
+	reader, err := sg.storage.Get(context.Background(), manifestKey(key))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var manifest domain.Manifest
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// deleteManifest removes the manifest of a deleted backup; a missing manifest is not an error
+func (sg *StorageGateway) deleteManifest(key string) {
+	sg.storage.Delete(context.Background(), manifestKey(key))
+}
+
+// BackupFormat returns the codec and encryption (nil if unencrypted) of a backup, taken
+// from its manifest when there is one and from the file name's extensions otherwise
+func BackupFormat(name string, manifest *domain.Manifest) (Codec, Encryption, error) {
+	if manifest == nil {
+		return CodecForFile(name), EncryptionForFile(name), nil
+	}
+
+	codec, err := GetCodec(manifest.Compression)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.Encryption == nil {
+		return codec, nil, nil
+	}
+	encryption, err := GetEncryption(manifest.Encryption.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return codec, encryption, nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg := NewStorageGateway(storage)
+	if err := storage.Put(context.Background(), "shop/shop-20261016030000.sql.gz.age", strings.NewReader("dump")); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	manifest := &domain.Manifest{
+		ManifestVersion: domain.ManifestVersion,
+		Host:            "db.internal:3306",
+		Database:        "shop",
+		File:            "shop-20261016030000.sql.gz.age",
+		Dumper:          domain.DumperInfo{Tool: "mysqldump", Flags: []string{"--single-transaction"}},
+		StartedAt:       started,
+		FinishedAt:      started.Add(time.Minute),
+		Size:            4,
+		SHA256:          "88d4266fd4e6338d13b845fcf289579d209c897823b9217da3e161936f031589",
+		Compression:     "gzip",
+		Encryption:      &domain.EncryptionInfo{Mode: "age", Recipients: []string{"age1example"}},
+	}
+	if err := sg.StoreManifest("shop", manifest.File, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := sg.LoadManifest("shop/shop-20261016030000.sql.gz.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, manifest) {
+		t.Errorf("LoadManifest = %+v, want %+v", loaded, manifest)
+	}
+
+	// The sidecar is not listed as a backup and goes away with its backup
+	backups, err := sg.ListBackups("shop")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups = %v, %v; want only the backup", backups, err)
+	}
+	if err := sg.DeleteBackup("shop/shop-20261016030000.sql.gz.age"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sg.LoadManifest("shop/shop-20261016030000.sql.gz.age"); err == nil {
+		t.Error("LoadManifest after DeleteBackup: expected an error")
+	}
+}
+
+func TestBackupFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		manifest    *domain.Manifest
+		codec       string
+		encryption  string
+		errContains string
+	}{
+		{"from file name", "shop-20261016030000.sql.zst.gpg", nil, "zstd", "gpg", ""},
+		{"plain file name", "shop-20261016030000.sql", nil, "none", "", ""},
+		{"manifest wins over the file name", "shop-20261016030000.sql.gz",
+			&domain.Manifest{Compression: "zstd", Encryption: &domain.EncryptionInfo{Mode: "age"}}, "zstd", "age", ""},
+		{"unencrypted manifest", "shop-20261016030000.sql.gz.age", &domain.Manifest{Compression: "gzip"}, "gzip", "", ""},
+		{"unknown codec", "shop-20261016030000.sql.br", &domain.Manifest{Compression: "brotli"}, "", "", "unknown compression"},
+		{"unknown encryption", "shop-20261016030000.sql.gz", &domain.Manifest{Compression: "gzip", Encryption: &domain.EncryptionInfo{Mode: "rot13"}}, "", "", "unknown encryption"},
+	}
+	for _, tt := range tests {
+		codec, encryption, err := BackupFormat(tt.file, tt.manifest)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.errContains)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		gotEncryption := ""
+		if encryption != nil {
+			gotEncryption = encryption.Name()
+		}
+		if codec.Name() != tt.codec || gotEncryption != tt.encryption {
+			t.Errorf("%s: BackupFormat = %s, %q; want %s, %q", tt.name, codec.Name(), gotEncryption, tt.codec, tt.encryption)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"net"
 	"os"
-	"path/filepath"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -69,14 +68,8 @@ func (t *SSHTunnel) findFreePort() (int, error) {
 }
 
 // loadSSHKey loads SSH private key from file
-func (t *SSHTunnel) loadSSHKey(keyPath string) (ssh.Signer, error) {
-	expandedPath := os.ExpandEnv(keyPath)
-	if len(expandedPath) >= 2 && expandedPath[:2] == "~/" {
-		home, _ := os.UserHomeDir()
-		expandedPath = filepath.Join(home, expandedPath[2:])
-	}
-	
-	keyData, err := os.ReadFile(expandedPath)
+func loadSSHKey(keyPath string) (ssh.Signer, error) {
+	keyData, err := os.ReadFile(expandHome(keyPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read SSH key file: %w", err)
 	}
@@ -91,8 +84,8 @@ func (t *SSHTunnel) loadSSHKey(keyPath string) (ssh.Signer, error) {
 }
 
 // createSSHClient creates and configures SSH client
-func (t *SSHTunnel) createSSHClient(host string, port int, user string, keyPath string) (*ssh.Client, error) {
-	key, err := t.loadSSHKey(keyPath)
+func createSSHClient(host string, port int, user string, keyPath string) (*ssh.Client, error) {
+	key, err := loadSSHKey(keyPath)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +106,64 @@ func (t *SSHTunnel) createSSHClient(host string, port int, user string, keyPath
 	return client, nil
 }
 
+// dialSSH connects to an SSH server, hopping through a bastion host when one is set.
+// The bastion client (nil for direct connections) must be closed after the target client.
+func dialSSH(host string, port int, user string, keyPath string,
+	bastionHost string, bastionPort int, bastionUser string, bastionKeyPath string) (*ssh.Client, *ssh.Client, error) {
+	
+	if bastionHost == "" {
+		// Simple SSH: direct connection
+		targetClient, err := createSSHClient(host, port, user, keyPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to SSH host: %w", err)
+		}
+		return targetClient, nil, nil
+	}
+	
+	// Double hop: connect through bastion to target
+	// Step 1: Connect to bastion
+	if bastionUser == "" {
+		bastionUser = user
+	}
+	if bastionKeyPath == "" {
+		bastionKeyPath = keyPath
+	}
+	
+	bastionClient, err := createSSHClient(bastionHost, bastionPort, bastionUser, bastionKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to bastion: %w", err)
+	}
+	
+	// Step 2: Create channel through bastion to target SSH server
+	conn, err := bastionClient.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		bastionClient.Close()
+		return nil, nil, fmt.Errorf("failed to dial target through bastion: %w", err)
+	}
+	
+	// Step 3: Create SSH transport over the channel
+	key, err := loadSSHKey(keyPath)
+	if err != nil {
+		conn.Close()
+		bastionClient.Close()
+		return nil, nil, fmt.Errorf("failed to load SSH key: %w", err)
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, fmt.Sprintf("%s:%d", host, port), config)
+	if err != nil {
+		conn.Close()
+		bastionClient.Close()
+		return nil, nil, fmt.Errorf("failed to create SSH connection through bastion: %w", err)
+	}
+	
+	return ssh.NewClient(ncc, chans, reqs), bastionClient, nil
+}
+
 // Start starts the SSH tunnel and returns local port
 func (t *SSHTunnel) Start() (int, error) {
 	if t.localPort != 0 {
@@ -125,60 +176,9 @@ func (t *SSHTunnel) Start() (int, error) {
 	}
 	t.localPort = port
 	
-	if t.bastionHost != "" {
-		// Double hop: connect through bastion to target
-		// Step 1: Connect to bastion
-		bastionUser := t.bastionUser
-		if bastionUser == "" {
-			bastionUser = t.sshUser
-		}
-		bastionKeyPath := t.bastionKeyPath
-		if bastionKeyPath == "" {
-			bastionKeyPath = t.sshKeyPath
-		}
-		
-		bastionClient, err := t.createSSHClient(t.bastionHost, t.bastionPort, bastionUser, bastionKeyPath)
-		if err != nil {
-			return 0, fmt.Errorf("failed to connect to bastion: %w", err)
-		}
-		t.bastionConn = bastionClient
-		
-		// Step 2: Create channel through bastion to target SSH server
-		conn, err := bastionClient.Dial("tcp", fmt.Sprintf("%s:%d", t.sshHost, t.sshPort))
-		if err != nil {
-			bastionClient.Close()
-			return 0, fmt.Errorf("failed to dial target through bastion: %w", err)
-		}
-		
-		// Step 3: Create SSH transport over the channel
-		key, err := t.loadSSHKey(t.sshKeyPath)
-		if err != nil {
-			conn.Close()
-			bastionClient.Close()
-			return 0, fmt.Errorf("failed to load SSH key: %w", err)
-		}
-		config := &ssh.ClientConfig{
-			User:            t.sshUser,
-			Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		}
-		
-		ncc, chans, reqs, err := ssh.NewClientConn(conn, fmt.Sprintf("%s:%d", t.sshHost, t.sshPort), config)
-		if err != nil {
-			conn.Close()
-			bastionClient.Close()
-			return 0, fmt.Errorf("failed to create SSH connection through bastion: %w", err)
-		}
-		
-		targetClient := ssh.NewClient(ncc, chans, reqs)
-		t.targetConn = targetClient
-	} else {
-		// Simple SSH tunnel: direct connection
-		targetClient, err := t.createSSHClient(t.sshHost, t.sshPort, t.sshUser, t.sshKeyPath)
-		if err != nil {
-			return 0, fmt.Errorf("failed to connect to SSH host: %w", err)
-		}
-		t.targetConn = targetClient
+	if err := t.Connect(); err != nil {
+		t.localPort = 0
+		return 0, err
 	}
 	
 	// Create local listener
@@ -259,6 +259,36 @@ func (t *SSHTunnel) forwardTunnel() {
 	}
 }
 
+// Connect opens the SSH connection used for forwarding and running commands, unless it
+// is already open
+func (t *SSHTunnel) Connect() error {
+	if t.targetConn != nil {
+		return nil
+	}
+	targetClient, bastionClient, err := dialSSH(t.sshHost, t.sshPort, t.sshUser, t.sshKeyPath,
+		t.bastionHost, t.bastionPort, t.bastionUser, t.bastionKeyPath)
+	if err != nil {
+		return err
+	}
+	t.targetConn = targetClient
+	t.bastionConn = bastionClient
+	return nil
+}
+
+// Run runs a shell command on the SSH host; Connect must have been called
+func (t *SSHTunnel) Run(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	session, err := t.targetConn.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+	
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(command)
+}
+
 // Stop stops the SSH tunnel
 func (t *SSHTunnel) Stop() {
 	close(t.stopChan)
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	// ETag is the backend's entity tag, if it has one (S3)
+	ETag string
+}
+
+// Storage is a backup storage backend. Keys are slash-separated and relative
+// to the backend's configured base path (e.g. "<db>/<db>-<timestamp>.sql.gz").
+type Storage interface {
+	// Put stores the content of r under key
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the object stored under key for reading
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns all objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+	// Stat returns information about the object stored under key
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Location returns a human-readable location of key for log output
+	Location(key string) string
+}
+
+// LockInfo describes the deletion protection of a stored object
+type LockInfo struct {
+	// Mode is the protection mode (e.g. GOVERNANCE, COMPLIANCE or protected)
+	Mode        string
+	RetainUntil time.Time
+	LegalHold   bool
+}
+
+// Locked reports whether the object may not be deleted at now
+func (l *LockInfo) Locked(now time.Time) bool {
+	return l != nil && (l.LegalHold || now.Before(l.RetainUntil))
+}
+
+// String describes the lock for log output
+func (l *LockInfo) String() string {
+	if l.LegalHold {
+		return "legal hold"
+	}
+	return fmt.Sprintf("%s until %s", strings.ToLower(l.Mode), l.RetainUntil.Format(time.RFC3339))
+}
+
+// LockingStorage is implemented by backends that can protect objects from deletion
+type LockingStorage interface {
+	// LockInfo returns the protection of the object stored under key, or nil if it has none
+	LockInfo(ctx context.Context, key string) (*LockInfo, error)
+}
+
+// ChecksumStorage is implemented by backends that can record the checksum of a stored
+// object with the object itself, in addition to the manifest
+type ChecksumStorage interface {
+	// SetChecksum records the hex-encoded SHA-256 of the object stored under key
+	SetChecksum(ctx context.Context, key string, sha256 string) error
+}
+
+// VersionedStorage is implemented by backends that may keep old versions of deleted objects
+type VersionedStorage interface {
+	// PurgeVersions deletes the old versions of objects under prefix whose lock has expired
+	// and returns how many it deleted and how many are still locked
+	PurgeVersions(ctx context.Context, prefix string) (purged int, locked int, err error)
+}
+
+// StorageConfig holds the settings used to create a storage backend.
+// Empty fields fall back to the driver's .env settings.
+type StorageConfig struct {
+	Driver                 string
+	Path                   string
+	S3Bucket               string
+	AWSAccessKeyID         string
+	AWSSecretAccessKey     string
+	S3Endpoint             string
+	S3Region               string
+	S3ForcePathStyle       bool
+	S3ServerSideEncryption string
+	S3KMSKeyID             string
+	S3StorageClass         string
+	S3ChecksumAlgorithm    string
+	S3Tags                 map[string]string
+	S3Metadata             map[string]string
+	S3ObjectLockMode       string
+	S3ObjectLockRetention  string
+	S3LegalHold            bool
+	LocalImmutableMinAge   string
+	SFTPHost               string
+	SFTPPort               int
+	SFTPUser               string
+	SFTPKeyPath            string
+	SFTPBastionHost        string
+	SFTPBastionPort        int
+	SFTPBastionUser        string
+	SFTPBastionKeyPath     string
+}
+
+// StorageFactory creates a storage backend from its configuration
+type StorageFactory func(cfg StorageConfig) (Storage, error)
+
+var storageDrivers = make(map[string]StorageFactory)
+
+// RegisterStorage registers a storage backend under a driver name
+func RegisterStorage(driver string, factory StorageFactory) {
+	storageDrivers[strings.ToLower(driver)] = factory
+}
+
+// StorageDrivers returns the names of all registered storage drivers
+func StorageDrivers() []string {
+	names := make([]string, 0, len(storageDrivers))
+	for name := range storageDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStorage creates the storage backend selected by cfg.Driver
+func NewStorage(cfg StorageConfig) (Storage, error) {
+	factory, ok := storageDrivers[strings.ToLower(cfg.Driver)]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver '%s' (available: %s)", cfg.Driver, strings.Join(StorageDrivers(), ", "))
+	}
+	return factory(cfg)
+}
+
+// partialSuffix marks files that are still being written
+const partialSuffix = ".partial"
+
+// partialPath returns the hidden temporary name used while writing path
+func partialPath(p string) string {
+	dir, name := pathSplit(p)
+	return dir + "." + name + partialSuffix
+}
+
+// pathSplit splits p after its final slash (or OS separator)
+func pathSplit(p string) (string, string) {
+	i := strings.LastIndexAny(p, "/"+string(os.PathSeparator))
+	return p[:i+1], p[i+1:]
+}
+
+// isPartialFile reports whether a key refers to an unfinished upload
+func isPartialFile(key string) bool {
+	_, name := pathSplit(key)
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, partialSuffix)
+}
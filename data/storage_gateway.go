@@ -3,184 +3,256 @@ package data
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"sort"
-	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/magicstack-llp/db-backup-go/domain"
 )
 
 // StorageGateway handles backup storage operations
 type StorageGateway struct {
-	backupDir string
-	s3Client  *s3.Client
-	s3Bucket  string
-	s3Path    string
+	storage Storage
+	// out receives the gateway's status messages
+	out io.Writer
 }
 
 // NewStorageGateway creates a new StorageGateway instance
-func NewStorageGateway(backupDir string, s3Bucket string, s3Path string,
-	awsAccessKeyID string, awsSecretAccessKey string) (*StorageGateway, error) {
-	
-	sg := &StorageGateway{
-		backupDir: backupDir,
-		s3Bucket:  s3Bucket,
-		s3Path:    s3Path,
-	}
-	
-	// Setup S3 client if bucket is provided
-	if s3Bucket != "" {
-		ctx := context.Background()
-		cfg, err := config.LoadDefaultConfig(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load AWS config: %w", err)
-		}
-		
-		// Override credentials if provided
-		if awsAccessKeyID != "" && awsSecretAccessKey != "" {
-			cfg.Credentials = credentials.NewStaticCredentialsProvider(awsAccessKeyID, awsSecretAccessKey, "")
-		}
-		
-		sg.s3Client = s3.NewFromConfig(cfg)
+func NewStorageGateway(storage Storage) *StorageGateway {
+	return &StorageGateway{
+		storage: storage,
+		out:     os.Stdout,
 	}
-	
-	return sg, nil
 }
 
-// StoreBackup stores a backup file (local or S3)
-func (sg *StorageGateway) StoreBackup(backupPath string, dbName string, s3Bucket string, s3Key string) error {
-	if s3Bucket != "" && s3Key != "" {
-		return sg.storeS3Backup(backupPath, s3Bucket, s3Key)
-	}
-	return sg.storeLocalBackup(backupPath)
+// WithOutput returns a gateway for the same storage that writes its status messages to w
+func (sg *StorageGateway) WithOutput(w io.Writer) *StorageGateway {
+	clone := *sg
+	clone.out = w
+	return &clone
+}
+
+// Output returns where the gateway writes its status messages
+func (sg *StorageGateway) Output() io.Writer {
+	return sg.out
 }
 
-// storeLocalBackup stores backup locally
-func (sg *StorageGateway) storeLocalBackup(backupPath string) error {
-	fmt.Printf("Successfully created local backup: %s\n", backupPath)
+// backupKey returns the storage key of a backup file for a database
+func backupKey(dbName string, fileName string) string {
+	return path.Join(dbName, fileName)
+}
+
+// StoreBackup streams a backup from r into the database's folder
+func (sg *StorageGateway) StoreBackup(r io.Reader, dbName string, fileName string) error {
+	key := backupKey(dbName, fileName)
+	if err := sg.storage.Put(context.Background(), key, r); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(sg.out, "Successfully stored backup: %s\n", sg.storage.Location(key))
 	return nil
 }
 
-// storeS3Backup uploads backup to S3
-func (sg *StorageGateway) storeS3Backup(backupPath string, s3Bucket string, s3Key string) error {
-	file, err := os.Open(backupPath)
+// RecordChecksum stores the SHA-256 of a backup with the stored object where the backend
+// supports it
+func (sg *StorageGateway) RecordChecksum(dbName string, fileName string, sha256 string) error {
+	checksums, ok := sg.storage.(ChecksumStorage)
+	if !ok {
+		return nil
+	}
+	return checksums.SetChecksum(context.Background(), backupKey(dbName, fileName), sha256)
+}
+
+// BackupLocation returns where a backup file of a database is (or would be) stored
+func (sg *StorageGateway) BackupLocation(dbName string, fileName string) string {
+	return sg.storage.Location(backupKey(dbName, fileName))
+}
+
+// ListBackups returns the backups stored for a database (newest first)
+func (sg *StorageGateway) ListBackups(dbName string) ([]ObjectInfo, error) {
+	objects, err := sg.storage.List(context.Background(), dbName+"/")
 	if err != nil {
-		return fmt.Errorf("failed to open backup file: %w", err)
-	}
-	defer file.Close()
-	
-	ctx := context.Background()
-	_, err = sg.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s3Bucket),
-		Key:    aws.String(s3Key),
-		Body:   file,
+		return nil, err
+	}
+
+	var backups []ObjectInfo
+	for _, obj := range objects {
+		if isBackupFile(obj.Key) {
+			backups = append(backups, obj)
+		}
+	}
+
+	// Sort by backup time (newest first)
+	sort.Slice(backups, func(i, j int) bool {
+		return BackupTime(backups[i]).After(BackupTime(backups[j]))
 	})
-	
+
+	return backups, nil
+}
+
+// BackupTime returns when a backup was taken: the timestamp in its file name,
+// or its modification time for files that don't carry one
+func BackupTime(obj ObjectInfo) time.Time {
+	if t, ok := domain.ParseBackupTime(obj.Key); ok {
+		return t
+	}
+	return obj.ModTime
+}
+
+// ListAllBackups returns the backups of every database (newest first)
+func (sg *StorageGateway) ListAllBackups() ([]ObjectInfo, error) {
+	objects, err := sg.storage.List(context.Background(), "")
 	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %w", err)
+		return nil, err
+	}
+
+	var backups []ObjectInfo
+	for _, obj := range objects {
+		// Backups live in a folder per database
+		if path.Dir(obj.Key) != "." && isBackupFile(obj.Key) {
+			backups = append(backups, obj)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return BackupTime(backups[i]).After(BackupTime(backups[j]))
+	})
+
+	return backups, nil
+}
+
+// StatBackup returns information about a stored backup by its key ("<db>/<file>")
+func (sg *StorageGateway) StatBackup(key string) (*ObjectInfo, error) {
+	return sg.storage.Stat(context.Background(), key)
+}
+
+// Location returns where the backup with key is stored
+func (sg *StorageGateway) Location(key string) string {
+	return sg.storage.Location(key)
+}
+
+// DeleteBackup deletes a single backup by its key; locked backups are refused
+func (sg *StorageGateway) DeleteBackup(key string) error {
+	if lock := sg.LockInfo(key); lock.Locked(time.Now()) {
+		return fmt.Errorf("backup %s is locked (%s)", sg.storage.Location(key), lock)
+	}
+	if err := sg.storage.Delete(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", sg.storage.Location(key), err)
 	}
-	
-	fmt.Printf("Successfully uploaded %s to %s/%s\n", backupPath, s3Bucket, s3Key)
+	sg.deleteManifest(key)
 	return nil
 }
 
-// CleanupBackups removes old backups based on retention count
-func (sg *StorageGateway) CleanupBackups(dbName string, retentionCount int, s3Bucket string, s3Path string) error {
-	if s3Bucket != "" && s3Path != "" {
-		return sg.cleanupS3Backups(dbName, retentionCount, s3Bucket, s3Path)
+// OpenBackup opens a stored backup for reading
+func (sg *StorageGateway) OpenBackup(dbName string, backupName string) (io.ReadCloser, error) {
+	return sg.storage.Get(context.Background(), backupKey(dbName, backupName))
+}
+
+// Close releases connections held by the storage backend
+func (sg *StorageGateway) Close() {
+	if closer, ok := sg.storage.(io.Closer); ok {
+		closer.Close()
 	}
-	return sg.cleanupLocalBackups(dbName, retentionCount)
 }
 
-// cleanupLocalBackups removes old local backups
-func (sg *StorageGateway) cleanupLocalBackups(dbName string, retentionCount int) error {
-	dbBackupDir := filepath.Join(sg.backupDir, dbName)
-	if _, err := os.Stat(dbBackupDir); os.IsNotExist(err) {
+// LockInfo returns the deletion protection of a backup, or nil if the backend has none
+func (sg *StorageGateway) LockInfo(key string) *LockInfo {
+	locking, ok := sg.storage.(LockingStorage)
+	if !ok {
 		return nil
 	}
-	
-	entries, err := os.ReadDir(dbBackupDir)
+	lock, err := locking.LockInfo(context.Background(), key)
 	if err != nil {
-		return fmt.Errorf("failed to read backup directory: %w", err)
-	}
-	
-	// Filter backup files (.gz or .sql)
-	var backups []os.FileInfo
-	for _, entry := range entries {
-		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".gz") || strings.HasSuffix(entry.Name(), ".sql")) {
-			info, err := entry.Info()
-			if err != nil {
-				continue
-			}
-			backups = append(backups, info)
-		}
+		// Let the delete attempt report the problem
+		return nil
 	}
-	
-	// Sort by modification time (newest first)
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].ModTime().After(backups[j].ModTime())
-	})
-	
-	// Remove old backups
-	if len(backups) > retentionCount {
-		for _, oldBackup := range backups[retentionCount:] {
-			backupPath := filepath.Join(dbBackupDir, oldBackup.Name())
-			if err := os.Remove(backupPath); err != nil {
-				fmt.Printf("Failed to remove old backup %s: %v\n", oldBackup.Name(), err)
-			} else {
-				fmt.Printf("Removed old local backup: %s\n", oldBackup.Name())
-			}
+	return lock
+}
+
+// PlanCleanup returns the stored backups of a database that the retention policy doesn't keep.
+// Pending backups (about to be stored) take part in the decision but are never returned.
+func (sg *StorageGateway) PlanCleanup(dbName string, policy domain.RetentionPolicy, pending ...domain.Backup) ([]domain.Backup, error) {
+	backups, err := sg.ListBackups(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]domain.Backup, 0, len(backups)+len(pending))
+	for _, obj := range backups {
+		candidates = append(candidates, domain.Backup{Key: obj.Key, Time: BackupTime(obj), Size: obj.Size})
+	}
+	candidates = append(candidates, pending...)
+
+	_, prune := policy.Apply(candidates, time.Now())
+	var stored []domain.Backup
+	for _, backup := range prune {
+		if !isPending(backup, pending) {
+			stored = append(stored, backup)
 		}
 	}
-	
-	return nil
+	return stored, nil
 }
 
-// cleanupS3Backups removes old S3 backups
-func (sg *StorageGateway) cleanupS3Backups(dbName string, retentionCount int, s3Bucket string, s3Path string) error {
-	prefix := fmt.Sprintf("%s/%s/", s3Path, dbName)
-	ctx := context.Background()
-	
-	// List objects with prefix
-	paginator := s3.NewListObjectsV2Paginator(sg.s3Client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(s3Bucket),
-		Prefix:  aws.String(prefix),
-	})
-	
-	var objects []types.Object
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to list S3 objects: %w", err)
+// isPending reports whether backup is one of the pending backups
+func isPending(backup domain.Backup, pending []domain.Backup) bool {
+	for _, p := range pending {
+		if p.Key == backup.Key {
+			return true
 		}
-		objects = append(objects, page.Contents...)
 	}
-	
-	// Sort by LastModified (newest first)
-	sort.Slice(objects, func(i, j int) bool {
-		return objects[i].LastModified.After(*objects[j].LastModified)
-	})
-	
+	return false
+}
+
+// CleanupBackups removes the backups of a database that the retention policy doesn't keep.
+// With dryRun set it only reports what would be removed; pending is passed to PlanCleanup.
+func (sg *StorageGateway) CleanupBackups(dbName string, policy domain.RetentionPolicy, dryRun bool, pending ...domain.Backup) error {
+	prune, err := sg.PlanCleanup(dbName, policy, pending...)
+	if err != nil {
+		return err
+	}
+
 	// Remove old backups
-	if len(objects) > retentionCount {
-		for _, oldBackup := range objects[retentionCount:] {
-			_, err := sg.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
-				Bucket: aws.String(s3Bucket),
-				Key:    oldBackup.Key,
-			})
-			if err != nil {
-				fmt.Printf("Failed to remove old S3 backup %s: %v\n", *oldBackup.Key, err)
-			} else {
-				fmt.Printf("Removed old S3 backup: %s\n", *oldBackup.Key)
-			}
+	for _, oldBackup := range prune {
+		location := sg.storage.Location(oldBackup.Key)
+		if lock := sg.LockInfo(oldBackup.Key); lock.Locked(time.Now()) {
+			fmt.Fprintf(sg.out, "Keeping locked backup %s (%s)\n", location, lock)
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(sg.out, "Would remove old backup: %s\n", location)
+			continue
 		}
+		if err := sg.storage.Delete(context.Background(), oldBackup.Key); err != nil {
+			fmt.Fprintf(sg.out, "Failed to remove old backup %s: %v\n", location, err)
+		} else {
+			sg.deleteManifest(oldBackup.Key)
+			fmt.Fprintf(sg.out, "Removed old backup: %s\n", location)
+		}
+	}
+	if !dryRun {
+		sg.purgeVersions(dbName)
 	}
-	
+
 	return nil
 }
 
+// purgeVersions removes old versions of a database's deleted backups that versioned
+// storage still keeps, and reports those that are locked
+func (sg *StorageGateway) purgeVersions(dbName string) {
+	versioned, ok := sg.storage.(VersionedStorage)
+	if !ok {
+		return
+	}
+	purged, locked, err := versioned.PurgeVersions(context.Background(), dbName+"/")
+	if purged > 0 {
+		fmt.Fprintf(sg.out, "Removed %d old object version(s) of %s backups\n", purged, dbName)
+	}
+	if locked > 0 {
+		fmt.Fprintf(sg.out, "Keeping %d locked old object version(s) of %s backups until their retention expires\n", locked, dbName)
+	}
+	if err != nil {
+		fmt.Fprintf(sg.out, "Failed to remove old object versions of %s backups: %v\n", dbName, err)
+	}
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func TestListBackups(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only dumps are listed, newest first
+	base := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"shop-old.sql.gz", 48 * time.Hour},
+		{"shop-new.sql", 0},
+		{"shop-mid.sql.gz", 24 * time.Hour},
+		{"notes.txt", 0},
+	}
+	for _, file := range files {
+		key := backupKey("shop", file.name)
+		if err := storage.Put(context.Background(), key, strings.NewReader("dump")); err != nil {
+			t.Fatal(err)
+		}
+		modTime := base.Add(-file.age)
+		if err := os.Chtimes(filepath.Join(dir, "shop", file.name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := NewStorageGateway(storage).ListBackups("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, backup := range backups {
+		keys = append(keys, backup.Key)
+	}
+	want := "shop/shop-new.sql,shop/shop-mid.sql.gz,shop/shop-old.sql.gz"
+	if got := strings.Join(keys, ","); got != want {
+		t.Errorf("ListBackups = %s, want %s", got, want)
+	}
+}
+
+func TestStoreBackupStreamsReader(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg := NewStorageGateway(storage)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte("-- dump\n"))
+		writer.Close()
+	}()
+	if err := sg.StoreBackup(reader, "shop", "shop-1.sql"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "shop", "shop-1.sql"))
+	if err != nil || string(content) != "-- dump\n" {
+		t.Errorf("stored %q, %v", content, err)
+	}
+
+	// A failing dump aborts the upload and leaves no partial backup behind
+	reader, writer = io.Pipe()
+	dumpErr := errors.New("mysqldump failed")
+	go func() {
+		writer.Write([]byte("-- partial"))
+		writer.CloseWithError(dumpErr)
+	}()
+	if err := sg.StoreBackup(reader, "shop", "shop-2.sql"); !errors.Is(err, dumpErr) {
+		t.Errorf("StoreBackup error = %v, want %v", err, dumpErr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shop", "shop-2.sql")); !os.IsNotExist(err) {
+		t.Errorf("partial backup left behind: %v", err)
+	}
+}
+
+func TestCleanupBackups(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backups are ordered by the timestamp in their name, not by modification time
+	for _, name := range []string{"shop-20261016030000.sql.gz", "shop-20261014030000.sql.gz", "shop-20261015030000.sql.gz"} {
+		if err := storage.Put(context.Background(), backupKey("shop", name), strings.NewReader("dump")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sg := NewStorageGateway(storage)
+	policy := domain.RetentionPolicy{KeepLast: 2}
+	stored := func() string {
+		entries, err := os.ReadDir(filepath.Join(dir, "shop"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return strings.Join(names, ",")
+	}
+
+	// A dry run deletes nothing
+	if err := sg.CleanupBackups("shop", policy, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stored(), "shop-20261014030000.sql.gz,shop-20261015030000.sql.gz,shop-20261016030000.sql.gz"; got != want {
+		t.Errorf("after dry run: %s, want %s", got, want)
+	}
+
+	if err := sg.CleanupBackups("shop", policy, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stored(), "shop-20261015030000.sql.gz,shop-20261016030000.sql.gz"; got != want {
+		t.Errorf("after cleanup: %s, want %s", got, want)
+	}
+}
+
+func TestPlanCleanupCountsPendingBackup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "shop"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"shop-20261014030000.sql.gz", "shop-20261015030000.sql.gz", "shop-20261016030000.sql.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, "shop", name), []byte("dump"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg := NewStorageGateway(storage)
+	policy := domain.RetentionPolicy{KeepLast: 2}
+
+	prune, err := sg.PlanCleanup("shop", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := backupKeys(prune); !reflect.DeepEqual(got, []string{"shop/shop-20261014030000.sql.gz"}) {
+		t.Errorf("without pending backup: prune = %v", got)
+	}
+
+	// The dry run's backup takes a slot but is never returned, as it isn't stored
+	pending := domain.Backup{Key: "shop/shop-20261016120000.sql.gz", Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)}
+	prune, err = sg.PlanCleanup("shop", policy, pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"shop/shop-20261015030000.sql.gz", "shop/shop-20261014030000.sql.gz"}
+	if got := backupKeys(prune); !reflect.DeepEqual(got, want) {
+		t.Errorf("with pending backup: prune = %v, want %v", got, want)
+	}
+}
+
+func backupKeys(backups []domain.Backup) []string {
+	keys := make([]string, len(backups))
+	for i, backup := range backups {
+		keys[i] = backup.Key
+	}
+	return keys
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func init() {
+	RegisterStorage("local", NewLocalStorage)
+}
+
+// LocalStorage stores backups in a directory on the local filesystem
+type LocalStorage struct {
+	baseDir string
+	// immutableFor makes backups read-only and keeps db-backup from deleting them until
+	// they reach this age. It doesn't stop anyone else: removing a file only needs write
+	// permission on its directory.
+	immutableFor time.Duration
+}
+
+// NewLocalStorage creates a new LocalStorage instance
+func NewLocalStorage(cfg StorageConfig) (Storage, error) {
+	baseDir := cfg.Path
+	if baseDir == "" {
+		baseDir = os.Getenv("BACKUP_DIR")
+	}
+	if baseDir == "" {
+		return nil, fmt.Errorf("please specify --backup-dir, set path in connection, or set BACKUP_DIR in .env")
+	}
+
+	immutableFor, err := domain.ParseDuration(firstNonEmpty(cfg.LocalImmutableMinAge, os.Getenv("LOCAL_IMMUTABLE_MIN_AGE")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid immutable minimum age: %w", err)
+	}
+
+	return &LocalStorage{baseDir: baseDir, immutableFor: immutableFor}, nil
+}
+
+// fullPath converts a storage key into a filesystem path
+func (s *LocalStorage) fullPath(key string) string {
+	return filepath.Join(s.baseDir, filepath.FromSlash(key))
+}
+
+// Put writes r to a temporary file next to key and renames it into place on success
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	path := s.fullPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tmpPath := partialPath(path)
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to flush backup file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalize backup file: %w", err)
+	}
+
+	if s.immutableFor > 0 {
+		if err := os.Chmod(path, 0444); err != nil {
+			return fmt.Errorf("failed to make backup read-only: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get opens the file for key
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.fullPath(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return file, nil
+}
+
+// List walks the base directory for files whose key starts with prefix
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Only walk the directory part of the prefix
+	root := s.baseDir
+	if dir := filepath.Dir(filepath.FromSlash(prefix)); dir != "." {
+		root = filepath.Join(s.baseDir, dir)
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || isPartialFile(key) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	return objects, nil
+}
+
+// Delete removes the file for key; protected backups are refused until they reach the minimum age
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	lock, err := s.LockInfo(ctx, key)
+	if err != nil {
+		return err
+	}
+	if lock.Locked(time.Now()) {
+		return fmt.Errorf("backup is protected from deletion until %s", lock.RetainUntil.Format(time.RFC3339))
+	}
+	return os.Remove(s.fullPath(key))
+}
+
+// LockInfo reports until when db-backup won't delete the file for key
+func (s *LocalStorage) LockInfo(ctx context.Context, key string) (*LockInfo, error) {
+	if s.immutableFor == 0 {
+		return nil, nil
+	}
+	info, err := os.Stat(s.fullPath(key))
+	if err != nil {
+		return nil, err
+	}
+	return &LockInfo{Mode: "protected", RetainUntil: info.ModTime().Add(s.immutableFor)}, nil
+}
+
+// Stat returns information about the file for key
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := os.Stat(s.fullPath(key))
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Location returns the filesystem path of key
+func (s *LocalStorage) Location(key string) string {
+	return s.fullPath(key)
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewStorage(StorageConfig{Driver: "local", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"shop/shop-1.sql.gz", "shop/shop-2.sql.gz", "shopping/shopping-1.sql.gz"} {
+		if err := storage.Put(ctx, key, strings.NewReader("dump of "+key)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	r, err := storage.Get(ctx, "shop/shop-1.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "dump of shop/shop-1.sql.gz" {
+		t.Errorf("Get = %q", content)
+	}
+
+	info, err := storage.Stat(ctx, "shop/shop-2.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len("dump of shop/shop-2.sql.gz")) {
+		t.Errorf("Stat size = %d", info.Size)
+	}
+
+	// "shop/" must not match the neighbouring "shopping" folder
+	objects, err := storage.List(ctx, "shop/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "shop/shop-1.sql.gz,shop/shop-2.sql.gz" {
+		t.Errorf("List(shop/) = %v", keys)
+	}
+
+	if err := storage.Delete(ctx, "shop/shop-1.sql.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Stat(ctx, "shop/shop-1.sql.gz"); err == nil {
+		t.Error("Stat after Delete: expected an error")
+	}
+
+	objects, err = storage.List(ctx, "missing/")
+	if err != nil || len(objects) != 0 {
+		t.Errorf("List(missing/) = %v, %v; want no objects", objects, err)
+	}
+}
+
+func TestNewStorageUnknownDriver(t *testing.T) {
+	if _, err := NewStorage(StorageConfig{Driver: "ftp"}); err == nil || !strings.Contains(err.Error(), "local") {
+		t.Errorf("NewStorage(ftp) error = %v, want one listing the available drivers", err)
+	}
+}
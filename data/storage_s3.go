@@ -0,0 +1,693 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/magicstack-llp/db-backup-go/domain"
+)
+
+func init() {
+	RegisterStorage("s3", NewS3Storage)
+}
+
+const (
+	// defaultS3PartSizeMB allows objects up to ~640 GB within S3's 10,000 part limit
+	defaultS3PartSizeMB = 64
+	// defaultS3UploadConcurrency bounds upload buffering to PartSize * Concurrency
+	defaultS3UploadConcurrency = 2
+	// maxS3Tags is the number of tags S3 allows on an object
+	maxS3Tags = 10
+	// maxS3CopySize is the largest object CopyObject copies in one request
+	maxS3CopySize = 5 << 30
+	// s3CopyPartSize is the part size for copying larger objects with UploadPartCopy
+	s3CopyPartSize = 512 << 20
+)
+
+// S3Storage stores backups in an S3 bucket under a key prefix
+type S3Storage struct {
+	client       *s3.Client
+	uploader     *manager.Uploader
+	bucket       string
+	prefix       string
+	sse          types.ServerSideEncryption
+	kmsKeyID     string
+	storageClass types.StorageClass
+	tags         map[string]string
+	metadata     map[string]string
+	checksum     types.ChecksumAlgorithm
+	lockMode     types.ObjectLockMode
+	lockPeriod   time.Duration
+	legalHold    bool
+}
+
+// NewS3Storage creates a new S3Storage instance
+func NewS3Storage(cfg StorageConfig) (Storage, error) {
+	bucket := cfg.S3Bucket
+	if bucket == "" {
+		bucket = os.Getenv("S3_BUCKET")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("please set s3_bucket in connection, set S3_BUCKET in .env, or use --s3 with proper configuration")
+	}
+
+	prefix := cfg.Path
+	if prefix == "" {
+		prefix = os.Getenv("S3_PATH")
+	}
+	if prefix == "" {
+		prefix = "backups"
+	}
+
+	accessKeyID := cfg.AWSAccessKeyID
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	secretAccessKey := cfg.AWSSecretAccessKey
+	if secretAccessKey == "" {
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	// S3-compatible endpoints (MinIO, Ceph, Wasabi, R2)
+	endpoint := firstNonEmpty(cfg.S3Endpoint, os.Getenv("S3_ENDPOINT"))
+	region := firstNonEmpty(cfg.S3Region, os.Getenv("S3_REGION"))
+	forcePathStyle := cfg.S3ForcePathStyle
+	if !forcePathStyle {
+		forcePathStyle, _ = strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+	}
+	if endpoint != "" && region == "" {
+		// Most S3-compatible servers accept any region but the SDK requires one
+		region = "us-east-1"
+	}
+
+	ctx := context.Background()
+	var loadOptions []func(*config.LoadOptions) error
+	if region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Override credentials if provided
+	if accessKeyID != "" && secretAccessKey != "" {
+		awsCfg.Credentials = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = forcePathStyle
+	})
+
+	// Multipart upload settings; memory use is bounded by part size * concurrency
+	partSizeMB, _ := strconv.Atoi(os.Getenv("S3_PART_SIZE_MB"))
+	if partSizeMB <= 0 {
+		partSizeMB = defaultS3PartSizeMB
+	}
+	concurrency, _ := strconv.Atoi(os.Getenv("S3_UPLOAD_CONCURRENCY"))
+	if concurrency <= 0 {
+		concurrency = defaultS3UploadConcurrency
+	}
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = int64(partSizeMB) * 1024 * 1024
+		u.Concurrency = concurrency
+	})
+
+	// Object settings used by bucket lifecycle rules and cost allocation
+	sse, ok := enumValue(firstNonEmpty(cfg.S3ServerSideEncryption, os.Getenv("S3_SSE")), types.ServerSideEncryption("").Values())
+	if !ok {
+		return nil, fmt.Errorf("invalid S3 server-side encryption '%s' (available: %s)", sse, joinValues(sse.Values()))
+	}
+	kmsKeyID := firstNonEmpty(cfg.S3KMSKeyID, os.Getenv("S3_SSE_KMS_KEY_ID"))
+	if kmsKeyID != "" && !strings.HasPrefix(string(sse), "aws:kms") {
+		return nil, fmt.Errorf("an S3 KMS key ID requires server-side encryption aws:kms")
+	}
+	storageClass, ok := enumValue(firstNonEmpty(cfg.S3StorageClass, os.Getenv("S3_STORAGE_CLASS")), types.StorageClass("").Values())
+	if !ok {
+		return nil, fmt.Errorf("invalid S3 storage class '%s' (available: %s)", storageClass, joinValues(storageClass.Values()))
+	}
+	tags, err := mergeKeyValues(os.Getenv("S3_TAGS"), cfg.S3Tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_TAGS: %w", err)
+	}
+	// S3 rejects uploads with more tags; every backup is also tagged with its database
+	tagCount := len(tags)
+	if _, ok := tags["database"]; !ok {
+		tagCount++
+	}
+	if tagCount > maxS3Tags {
+		return nil, fmt.Errorf("too many S3 tags: S3 allows %d per object including the database tag, got %d", maxS3Tags, tagCount)
+	}
+	metadata, err := mergeKeyValues(os.Getenv("S3_METADATA"), cfg.S3Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_METADATA: %w", err)
+	}
+
+	// Object Lock (the bucket must have Object Lock enabled)
+	lockMode, ok := enumValue(firstNonEmpty(cfg.S3ObjectLockMode, os.Getenv("S3_OBJECT_LOCK_MODE")), types.ObjectLockMode("").Values())
+	if !ok {
+		return nil, fmt.Errorf("invalid S3 object lock mode '%s' (available: %s)", lockMode, joinValues(lockMode.Values()))
+	}
+	lockPeriod, err := domain.ParseDuration(firstNonEmpty(cfg.S3ObjectLockRetention, os.Getenv("S3_OBJECT_LOCK_RETENTION")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 object lock retention: %w", err)
+	}
+	if lockPeriod > 0 && lockMode == "" {
+		lockMode = types.ObjectLockModeGovernance
+	}
+	if lockMode != "" && lockPeriod == 0 {
+		return nil, fmt.Errorf("S3 object lock mode %s requires a retention period (S3_OBJECT_LOCK_RETENTION, e.g. 30d)", lockMode)
+	}
+	legalHold := cfg.S3LegalHold
+	if !legalHold {
+		legalHold, _ = strconv.ParseBool(os.Getenv("S3_LEGAL_HOLD"))
+	}
+
+	// Part checksums are on by default for AWS and required there with Object Lock. Several
+	// S3-compatible servers reject them, so they are off by default for custom endpoints.
+	checksumName := firstNonEmpty(cfg.S3ChecksumAlgorithm, os.Getenv("S3_CHECKSUM_ALGORITHM"))
+	if checksumName == "" && (endpoint == "" || lockMode != "" || legalHold) {
+		checksumName = string(types.ChecksumAlgorithmSha256)
+	}
+	var checksum types.ChecksumAlgorithm
+	if !strings.EqualFold(checksumName, "none") {
+		checksum, ok = enumValue(checksumName, types.ChecksumAlgorithm("").Values())
+		if !ok {
+			return nil, fmt.Errorf("invalid S3 checksum algorithm '%s' (available: %s, none)", checksumName, joinValues(checksum.Values()))
+		}
+	}
+
+	return &S3Storage{
+		client:       client,
+		uploader:     uploader,
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		sse:          sse,
+		kmsKeyID:     kmsKeyID,
+		storageClass: storageClass,
+		tags:         tags,
+		metadata:     metadata,
+		checksum:     checksum,
+		lockMode:     lockMode,
+		lockPeriod:   lockPeriod,
+		legalHold:    legalHold,
+	}, nil
+}
+
+// mergeKeyValues parses a "key=value,key=value" list and applies overrides on top of it
+func mergeKeyValues(list string, overrides map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("expected key=value, got '%s'", pair)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	for key, value := range overrides {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// enumValue matches value case-insensitively against the SDK's enum values;
+// an empty value is accepted and means "not set"
+func enumValue[T ~string](value string, known []T) (T, bool) {
+	if value == "" {
+		return "", true
+	}
+	for _, v := range known {
+		if strings.EqualFold(string(v), value) {
+			return v, true
+		}
+	}
+	return T(value), false
+}
+
+// joinValues formats SDK enum values for error messages
+func joinValues[T ~string](values []T) string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = string(v)
+	}
+	return strings.Join(names, ", ")
+}
+
+// objectKey converts a storage key into the full S3 object key
+func (s *S3Storage) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// objectTags returns the configured tags plus the database (the key's folder)
+func (s *S3Storage) objectTags(key string) map[string]string {
+	tags := make(map[string]string, len(s.tags)+1)
+	for name, value := range s.tags {
+		tags[name] = value
+	}
+	if dir := path.Dir(key); dir != "." {
+		tags["database"] = dir
+	}
+	return tags
+}
+
+// objectTagging encodes the object's tags as a query string
+func (s *S3Storage) objectTagging(key string) string {
+	tags := url.Values{}
+	for name, value := range s.objectTags(key) {
+		tags.Set(name, value)
+	}
+	return tags.Encode()
+}
+
+// Put streams r to S3 using multipart upload; an error reading r aborts the upload
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader) error {
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(s.objectKey(key)),
+		Body:                 r,
+		ServerSideEncryption: s.sse,
+		StorageClass:         s.storageClass,
+	}
+	if s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	if tagging := s.objectTagging(key); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	if len(s.metadata) > 0 {
+		input.Metadata = s.metadata
+	}
+	// S3 verifies the checksum of every part on upload, which only protects the transfer.
+	// The SHA-256 of the whole object is only known once the stream ends; SetChecksum
+	// adds it afterwards.
+	input.ChecksumAlgorithm = s.checksum
+	if s.lockMode != "" {
+		input.ObjectLockMode = s.lockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(s.lockPeriod))
+	}
+	if s.legalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+
+	_, err := s.uploader.Upload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	return nil
+}
+
+// SetChecksum records the SHA-256 of the object for key as its "sha256" metadata. S3
+// metadata can't be changed in place, so the object is copied onto itself with the
+// checksum added. On versioned buckets the superseded version is deleted unless it is
+// locked; PurgeVersions removes it once its lock has expired.
+func (s *S3Storage) SetChecksum(ctx context.Context, key string, sha256 string) error {
+	objectKey := s.objectKey(key)
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.Location(key), err)
+	}
+	if head.Metadata["sha256"] == sha256 {
+		return nil
+	}
+
+	metadata := make(map[string]string, len(head.Metadata)+1)
+	for name, value := range head.Metadata {
+		metadata[name] = value
+	}
+	metadata["sha256"] = sha256
+	source := (&url.URL{Path: s.bucket + "/" + objectKey}).EscapedPath()
+	versionID := aws.ToString(head.VersionId)
+	if versionID != "" && versionID != "null" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+
+	var newVersionID string
+	if aws.ToInt64(head.ContentLength) <= maxS3CopySize {
+		newVersionID, err = s.copyObject(ctx, key, source, head, metadata)
+	} else {
+		newVersionID, err = s.copyObjectParts(ctx, key, source, head, metadata)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add the sha256 metadata to %s: %w", s.Location(key), err)
+	}
+
+	locked := head.ObjectLockMode != "" || head.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn
+	if versionID == "" || versionID == "null" || versionID == newVersionID || locked {
+		return nil
+	}
+	return s.deleteVersion(ctx, s3Version{key: objectKey, id: versionID})
+}
+
+// copyObject copies the object for key onto itself with new metadata in one request,
+// keeping its tags and Object Lock settings. It returns the new version ID.
+func (s *S3Storage) copyObject(ctx context.Context, key string, source string, head *s3.HeadObjectOutput, metadata map[string]string) (string, error) {
+	input := &s3.CopyObjectInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(s.objectKey(key)),
+		CopySource:                aws.String(source),
+		Metadata:                  metadata,
+		MetadataDirective:         types.MetadataDirectiveReplace,
+		ContentType:               head.ContentType,
+		ServerSideEncryption:      s.sse,
+		StorageClass:              s.storageClass,
+		ChecksumAlgorithm:         s.checksum,
+		ObjectLockMode:            head.ObjectLockMode,
+		ObjectLockRetainUntilDate: head.ObjectLockRetainUntilDate,
+		ObjectLockLegalHoldStatus: head.ObjectLockLegalHoldStatus,
+	}
+	if s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	out, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.VersionId), nil
+}
+
+// copyObjectParts copies an object too large for CopyObject onto itself with a multipart
+// upload of UploadPartCopy parts. It returns the new version ID.
+func (s *S3Storage) copyObjectParts(ctx context.Context, key string, source string, head *s3.HeadObjectOutput, metadata map[string]string) (string, error) {
+	objectKey := s.objectKey(key)
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(objectKey),
+		Metadata:                  metadata,
+		ContentType:               head.ContentType,
+		ServerSideEncryption:      s.sse,
+		StorageClass:              s.storageClass,
+		ChecksumAlgorithm:         s.checksum,
+		ObjectLockMode:            head.ObjectLockMode,
+		ObjectLockRetainUntilDate: head.ObjectLockRetainUntilDate,
+		ObjectLockLegalHoldStatus: head.ObjectLockLegalHoldStatus,
+	}
+	if s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	// Unlike CopyObject, multipart uploads don't copy the source's tags
+	if tagging := s.objectTagging(key); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	upload, err := s.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	parts, err := s.copyParts(ctx, objectKey, source, upload.UploadId, aws.ToInt64(head.ContentLength))
+	if err == nil {
+		var out *s3.CompleteMultipartUploadOutput
+		out, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(objectKey),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err == nil {
+			return aws.ToString(out.VersionId), nil
+		}
+	}
+	s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(objectKey),
+		UploadId: upload.UploadId,
+	})
+	return "", err
+}
+
+// copyParts copies size bytes of source into the multipart upload uploadID
+func (s *S3Storage) copyParts(ctx context.Context, objectKey string, source string, uploadID *string, size int64) ([]types.CompletedPart, error) {
+	// S3 allows 10,000 parts of at most 5 GiB
+	partSize := max(int64(s3CopyPartSize), (size+9999)/10000)
+	var parts []types.CompletedPart
+	for start, number := int64(0), int32(1); start < size; start, number = start+partSize, number+1 {
+		end := min(start+partSize, size) - 1
+		out, err := s.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(objectKey),
+			CopySource:      aws.String(source),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			PartNumber:      aws.Int32(number),
+			UploadId:        uploadID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy part %d: %w", number, err)
+		}
+		part := types.CompletedPart{PartNumber: aws.Int32(number)}
+		if result := out.CopyPartResult; result != nil {
+			part.ETag = result.ETag
+			part.ChecksumCRC32 = result.ChecksumCRC32
+			part.ChecksumCRC32C = result.ChecksumCRC32C
+			part.ChecksumSHA1 = result.ChecksumSHA1
+			part.ChecksumSHA256 = result.ChecksumSHA256
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// Get downloads the object for key
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", s.Location(key), err)
+	}
+	return out.Body, nil
+}
+
+// List lists objects whose key starts with prefix
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.objectKey(prefix)),
+	})
+
+	var objects []ObjectInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{
+				Key:  strings.TrimPrefix(aws.ToString(obj.Key), s.objectKey("")),
+				Size: aws.ToInt64(obj.Size),
+				ETag: strings.Trim(aws.ToString(obj.ETag), "\""),
+			}
+			if obj.LastModified != nil {
+				info.ModTime = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+
+	return objects, nil
+}
+
+// Delete deletes every version of the object for key. On versioned buckets, which Object
+// Lock requires, deleting without a version only adds a delete marker and the data stays
+// billed. Backends without versioning fall back to a plain delete.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	objectKey := s.objectKey(key)
+	versions, err := s.listVersions(ctx, objectKey)
+	if err != nil && !isNotImplemented(err) {
+		return err
+	}
+	if len(versions) == 0 {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(objectKey),
+		})
+		return err
+	}
+	for _, version := range versions {
+		if version.key != objectKey {
+			continue
+		}
+		if err := s.deleteVersion(ctx, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// s3Version is a version or delete marker of an object in a versioned bucket
+type s3Version struct {
+	key          string
+	id           string
+	latest       bool
+	deleteMarker bool
+}
+
+// listVersions returns the versions and delete markers of the objects whose key starts with prefix
+func (s *S3Storage) listVersions(ctx context.Context, prefix string) ([]s3Version, error) {
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var versions []s3Version
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 object versions: %w", err)
+		}
+		for _, v := range page.Versions {
+			versions = append(versions, s3Version{key: aws.ToString(v.Key), id: aws.ToString(v.VersionId), latest: aws.ToBool(v.IsLatest)})
+		}
+		for _, m := range page.DeleteMarkers {
+			versions = append(versions, s3Version{key: aws.ToString(m.Key), id: aws.ToString(m.VersionId), latest: aws.ToBool(m.IsLatest), deleteMarker: true})
+		}
+	}
+	return versions, nil
+}
+
+// deleteVersion permanently deletes one version; S3 refuses versions that are still locked
+func (s *S3Storage) deleteVersion(ctx context.Context, version s3Version) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(version.key),
+		VersionId: aws.String(version.id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete version %s of %s: %w", version.id, version.key, err)
+	}
+	return nil
+}
+
+// PurgeVersions deletes the noncurrent versions of the objects under prefix whose lock has
+// expired, e.g. those of backups deleted while they were still locked or by other tools.
+// Delete markers go once no version of their object is left.
+func (s *S3Storage) PurgeVersions(ctx context.Context, prefix string) (int, int, error) {
+	versions, err := s.listVersions(ctx, s.objectKey(prefix))
+	if err != nil {
+		if isNotImplemented(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	purged, locked := 0, 0
+	remaining := make(map[string]int)
+	for _, version := range versions {
+		if version.deleteMarker {
+			continue
+		}
+		if version.latest {
+			remaining[version.key]++
+			continue
+		}
+		lock, err := s.lockInfo(ctx, version.key, version.id)
+		if err != nil {
+			return purged, locked, err
+		}
+		if lock.Locked(now) {
+			remaining[version.key]++
+			locked++
+			continue
+		}
+		if err := s.deleteVersion(ctx, version); err != nil {
+			return purged, locked, err
+		}
+		purged++
+	}
+	for _, version := range versions {
+		if version.deleteMarker && remaining[version.key] == 0 {
+			if err := s.deleteVersion(ctx, version); err != nil {
+				return purged, locked, err
+			}
+		}
+	}
+	return purged, locked, nil
+}
+
+// isNotImplemented reports whether an S3-compatible server lacks an API, e.g. versioning on R2
+func isNotImplemented(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotImplemented" || apiErr.ErrorCode() == "MethodNotAllowed")
+}
+
+// Stat returns information about the object for key
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), ETag: strings.Trim(aws.ToString(out.ETag), "\"")}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+	return info, nil
+}
+
+// LockInfo returns the Object Lock retention and legal hold of the object for key
+func (s *S3Storage) LockInfo(ctx context.Context, key string) (*LockInfo, error) {
+	return s.lockInfo(ctx, s.objectKey(key), "")
+}
+
+// lockInfo returns the Object Lock of a version of the object with the full key objectKey;
+// an empty versionID selects the current version
+func (s *S3Storage) lockInfo(ctx context.Context, objectKey string, versionID string) (*LockInfo, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectKey),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	out, err := s.client.HeadObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if out.ObjectLockMode == "" && out.ObjectLockLegalHoldStatus != types.ObjectLockLegalHoldStatusOn {
+		return nil, nil
+	}
+	lock := &LockInfo{
+		Mode:      string(out.ObjectLockMode),
+		LegalHold: out.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn,
+	}
+	if out.ObjectLockRetainUntilDate != nil {
+		lock.RetainUntil = *out.ObjectLockRetainUntilDate
+	}
+	return lock, nil
+}
+
+// Location returns the s3:// URL of key
+func (s *S3Storage) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.objectKey(key))
+}
@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 answers the requests SetChecksum makes for one versioned object
+type fakeS3 struct {
+	mu       sync.Mutex
+	headers  http.Header
+	requests []string
+	copied   http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path+"?"+r.URL.Query().Get("versionId"))
+	switch r.Method {
+	case http.MethodHead:
+		for name, values := range f.headers {
+			w.Header()[name] = values
+		}
+	case http.MethodPut:
+		f.copied = r.Header.Clone()
+		w.Header().Set("x-amz-version-id", "v2")
+		fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newFakeS3Storage(t *testing.T, fake *fakeS3) *S3Storage {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	storage, err := NewStorage(StorageConfig{
+		Driver:              "s3",
+		S3Bucket:            "backups",
+		Path:                "db",
+		S3Endpoint:          server.URL,
+		S3ForcePathStyle:    true,
+		S3ChecksumAlgorithm: "none",
+		AWSAccessKeyID:      "key",
+		AWSSecretAccessKey:  "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.(*S3Storage)
+}
+
+func TestS3SetChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		requests []string
+	}{
+		{
+			name:    "unversioned",
+			headers: map[string]string{"Content-Length": "4"},
+			requests: []string{
+				"HEAD /backups/db/shop/shop-1.sql.gz?",
+				"PUT /backups/db/shop/shop-1.sql.gz?",
+			},
+		},
+		{
+			name:    "versioned deletes the superseded version",
+			headers: map[string]string{"Content-Length": "4", "X-Amz-Version-Id": "v1"},
+			requests: []string{
+				"HEAD /backups/db/shop/shop-1.sql.gz?",
+				"PUT /backups/db/shop/shop-1.sql.gz?",
+				"DELETE /backups/db/shop/shop-1.sql.gz?v1",
+			},
+		},
+		{
+			name: "locked versions are kept",
+			headers: map[string]string{"Content-Length": "4", "X-Amz-Version-Id": "v1",
+				"X-Amz-Object-Lock-Mode": "GOVERNANCE", "X-Amz-Object-Lock-Retain-Until-Date": "2026-11-16T03:00:00Z"},
+			requests: []string{
+				"HEAD /backups/db/shop/shop-1.sql.gz?",
+				"PUT /backups/db/shop/shop-1.sql.gz?",
+			},
+		},
+		{
+			name:     "already recorded",
+			headers:  map[string]string{"Content-Length": "4", "X-Amz-Meta-Sha256": "abc123"},
+			requests: []string{"HEAD /backups/db/shop/shop-1.sql.gz?"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{headers: http.Header{"X-Amz-Meta-Team": {"dba"}}}
+			for name, value := range tt.headers {
+				fake.headers.Set(name, value)
+			}
+			storage := newFakeS3Storage(t, fake)
+
+			if err := storage.SetChecksum(context.Background(), "shop/shop-1.sql.gz", "abc123"); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.Join(fake.requests, "\n"), strings.Join(tt.requests, "\n"); got != want {
+				t.Errorf("requests:\n%s\nwant:\n%s", got, want)
+			}
+			if fake.copied == nil {
+				return
+			}
+			// Metadata is replaced, keeping what the object already had
+			if got := fake.copied.Get("X-Amz-Metadata-Directive"); got != "REPLACE" {
+				t.Errorf("metadata directive = %q, want REPLACE", got)
+			}
+			if got := fake.copied.Get("X-Amz-Meta-Sha256"); got != "abc123" {
+				t.Errorf("sha256 metadata = %q", got)
+			}
+			if got := fake.copied.Get("X-Amz-Meta-Team"); got != "dba" {
+				t.Errorf("team metadata = %q, want it kept", got)
+			}
+			source := "backups/db/shop/shop-1.sql.gz"
+			if version := tt.headers["X-Amz-Version-Id"]; version != "" {
+				source += "?versionId=" + version
+			}
+			if got := fake.copied.Get("X-Amz-Copy-Source"); got != source {
+				t.Errorf("copy source = %q, want %q", got, source)
+			}
+			if got, want := fake.copied.Get("X-Amz-Object-Lock-Mode"), tt.headers["X-Amz-Object-Lock-Mode"]; got != want {
+				t.Errorf("lock mode = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNewS3StorageTagLimit(t *testing.T) {
+	tags := make(map[string]string)
+	for i := 0; i < maxS3Tags-1; i++ {
+		tags[fmt.Sprintf("tag%d", i)] = "value"
+	}
+	cfg := StorageConfig{Driver: "s3", S3Bucket: "backups", S3Region: "us-east-1", S3Tags: tags}
+	if _, err := NewStorage(cfg); err != nil {
+		t.Fatalf("%d tags plus the database tag: %v", len(tags), err)
+	}
+
+	tags["tag9"] = "value"
+	if _, err := NewStorage(cfg); err == nil || !strings.Contains(err.Error(), "too many S3 tags") {
+		t.Errorf("%d tags plus the database tag: error = %v", len(tags), err)
+	}
+}
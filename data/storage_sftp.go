@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func init() {
+	RegisterStorage("sftp", NewSFTPStorage)
+}
+
+// SFTPStorage stores backups on a remote host over SFTP, optionally through a bastion host
+type SFTPStorage struct {
+	client      *sftp.Client
+	targetConn  *ssh.Client
+	bastionConn *ssh.Client
+	host        string
+	baseDir     string
+}
+
+// NewSFTPStorage creates a new SFTPStorage instance and connects to the remote host
+func NewSFTPStorage(cfg StorageConfig) (Storage, error) {
+	host := firstNonEmpty(cfg.SFTPHost, os.Getenv("SFTP_HOST"))
+	user := firstNonEmpty(cfg.SFTPUser, os.Getenv("SFTP_USER"))
+	keyPath := firstNonEmpty(cfg.SFTPKeyPath, os.Getenv("SFTP_KEY_PATH"))
+	if host == "" || user == "" || keyPath == "" {
+		return nil, fmt.Errorf("please set sftp_host, sftp_user and sftp_key_path in connection, or SFTP_HOST, SFTP_USER and SFTP_KEY_PATH in .env")
+	}
+
+	port := cfg.SFTPPort
+	if port == 0 {
+		port, _ = strconv.Atoi(os.Getenv("SFTP_PORT"))
+	}
+	if port == 0 {
+		port = 22
+	}
+
+	baseDir := firstNonEmpty(cfg.Path, os.Getenv("SFTP_PATH"))
+	if baseDir == "" {
+		return nil, fmt.Errorf("please set path in connection or SFTP_PATH in .env")
+	}
+
+	bastionHost := firstNonEmpty(cfg.SFTPBastionHost, os.Getenv("SFTP_BASTION_HOST"))
+	bastionPort := cfg.SFTPBastionPort
+	if bastionPort == 0 {
+		bastionPort, _ = strconv.Atoi(os.Getenv("SFTP_BASTION_PORT"))
+	}
+	if bastionPort == 0 {
+		bastionPort = 22
+	}
+	bastionUser := firstNonEmpty(cfg.SFTPBastionUser, os.Getenv("SFTP_BASTION_USER"))
+	bastionKeyPath := firstNonEmpty(cfg.SFTPBastionKeyPath, os.Getenv("SFTP_BASTION_KEY_PATH"))
+
+	targetConn, bastionConn, err := dialSSH(host, port, user, keyPath,
+		bastionHost, bastionPort, bastionUser, bastionKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(targetConn)
+	if err != nil {
+		targetConn.Close()
+		if bastionConn != nil {
+			bastionConn.Close()
+		}
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+
+	return &SFTPStorage{
+		client:      client,
+		targetConn:  targetConn,
+		bastionConn: bastionConn,
+		host:        host,
+		baseDir:     path.Clean(baseDir),
+	}, nil
+}
+
+// remotePath converts a storage key into a path on the remote host
+func (s *SFTPStorage) remotePath(key string) string {
+	return path.Join(s.baseDir, key)
+}
+
+// Put uploads r to a temporary remote file and renames it into place on success
+func (s *SFTPStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	remote := s.remotePath(key)
+	if err := s.client.MkdirAll(path.Dir(remote)); err != nil {
+		return fmt.Errorf("failed to create remote directory: %w", err)
+	}
+
+	tmpRemote := partialPath(remote)
+	file, err := s.client.Create(tmpRemote)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file: %w", err)
+	}
+
+	if _, err := file.ReadFrom(r); err != nil {
+		file.Close()
+		s.client.Remove(tmpRemote)
+		return fmt.Errorf("failed to upload over SFTP: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		s.client.Remove(tmpRemote)
+		return fmt.Errorf("failed to upload over SFTP: %w", err)
+	}
+
+	// Prefer the atomic posix-rename extension, fall back to plain rename
+	if err := s.client.PosixRename(tmpRemote, remote); err != nil {
+		if err := s.client.Rename(tmpRemote, remote); err != nil {
+			s.client.Remove(tmpRemote)
+			return fmt.Errorf("failed to finalize remote file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get opens the remote file for key
+func (s *SFTPStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.client.Open(s.remotePath(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.Location(key), err)
+	}
+	return file, nil
+}
+
+// List walks the remote base directory for files whose key starts with prefix
+func (s *SFTPStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Only walk the directory part of the prefix
+	root := s.baseDir
+	if dir := path.Dir(prefix); dir != "." {
+		root = path.Join(s.baseDir, dir)
+	}
+	if _, err := s.client.Stat(root); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read remote directory: %w", err)
+	}
+
+	var objects []ObjectInfo
+	walker := s.client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read remote directory: %w", err)
+		}
+		info := walker.Stat()
+		if info.IsDir() {
+			continue
+		}
+
+		key := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), s.baseDir), "/")
+		if !strings.HasPrefix(key, prefix) || isPartialFile(key) {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	return objects, nil
+}
+
+// Delete removes the remote file for key
+func (s *SFTPStorage) Delete(ctx context.Context, key string) error {
+	return s.client.Remove(s.remotePath(key))
+}
+
+// Stat returns information about the remote file for key
+func (s *SFTPStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := s.client.Stat(s.remotePath(key))
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Location returns the sftp:// URL of key
+func (s *SFTPStorage) Location(key string) string {
+	return fmt.Sprintf("sftp://%s%s", s.host, s.remotePath(key))
+}
+
+// Close closes the SFTP session and SSH connections
+func (s *SFTPStorage) Close() error {
+	s.client.Close()
+	s.targetConn.Close()
+	if s.bastionConn != nil {
+		s.bastionConn.Close()
+	}
+	return nil
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
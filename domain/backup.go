@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"path"
+	"regexp"
+	"time"
+)
+
+// BackupTimestampFormat is the timestamp embedded in backup file names
+const BackupTimestampFormat = "20060102150405"
+
+// backupTimestampPattern matches "<db>-<timestamp>.sql..." and, for physical backups and
+// binary logs, "<db>-<timestamp>.xbstream..." and "<binlog>-<timestamp>.binlog..." file names
+var backupTimestampPattern = regexp.MustCompile(`-(\d{14})\.(?:sql|xbstream|binlog)`)
+
+// Backup types
+const (
+	// BackupTypeLogical is an SQL dump of a database
+	BackupTypeLogical = "logical"
+	// BackupTypePhysical is a hot copy of the data files of a whole server
+	BackupTypePhysical = "physical"
+	// BackupTypeBinlog is an archived binary log, used for point-in-time recovery
+	BackupTypeBinlog = "binlog"
+)
+
+// Backup represents a stored backup file of a database
+type Backup struct {
+	// Key identifies the backup in its storage backend
+	Key string
+	// Time is when the backup was taken
+	Time time.Time
+	Size int64
+}
+
+// ParseBackupTime extracts the timestamp embedded in a backup file name
+func ParseBackupTime(name string) (time.Time, bool) {
+	match := backupTimestampPattern.FindStringSubmatch(path.Base(name))
+	if match == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(BackupTimestampFormat, match[1], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// ManifestVersion is the version of the manifest format written with new backups
+const ManifestVersion = 1
+
+// Manifest records how a backup was produced. It is stored next to the backup
+// and is the source of truth for its format, size and checksum.
+type Manifest struct {
+	ManifestVersion int    `json:"manifest_version"`
+	ToolVersion     string `json:"tool_version"`
+	Connection      string `json:"connection,omitempty"`
+	// Host is the source database server (host:port) as configured, not the tunnel endpoint
+	Host     string `json:"host"`
+	Database string `json:"database"`
+	// Type is BackupTypeLogical, BackupTypePhysical or BackupTypeBinlog; manifests of logical backups
+	// written before physical backups existed have none
+	Type string `json:"type,omitempty"`
+	File string `json:"file"`
+	// ServerFlavor is the kind of server, "mysql", "mariadb" or "postgresql"
+	ServerFlavor  string     `json:"server_flavor,omitempty"`
+	ServerVersion string     `json:"server_version,omitempty"`
+	Dumper        DumperInfo `json:"dumper"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    time.Time  `json:"finished_at"`
+	// DurationSeconds is the wall-clock time of the dump and upload
+	DurationSeconds float64 `json:"duration_seconds"`
+	// UncompressedSize is the size of the SQL dump
+	UncompressedSize int64 `json:"uncompressed_size"`
+	// CompressedSize is the size after compression, before encryption
+	CompressedSize int64 `json:"compressed_size"`
+	// Size is the size of the stored file
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 of the stored file
+	SHA256           string          `json:"sha256"`
+	Compression      string          `json:"compression"`
+	CompressionLevel int             `json:"compression_level,omitempty"`
+	Encryption       *EncryptionInfo `json:"encryption,omitempty"`
+	// Tables maps each table in the dump to the number of rows it contains
+	Tables map[string]int64 `json:"tables,omitempty"`
+	// Binlog is the binary log position a dump corresponds to, or the binary log an
+	// archive holds
+	Binlog *BinlogPosition `json:"binlog,omitempty"`
+}
+
+// DumperInfo describes the program that produced a dump
+type DumperInfo struct {
+	Tool    string   `json:"tool"`
+	Version string   `json:"version,omitempty"`
+	Flags   []string `json:"flags,omitempty"`
+}
+
+// BinlogPosition is a position in the binary logs of a MySQL or MariaDB server
+type BinlogPosition struct {
+	File     string `json:"file"`
+	Position int64  `json:"position,omitempty"`
+	// GTIDSet is the server's GTID state at the position, when it was recorded
+	GTIDSet string `json:"gtid_set,omitempty"`
+	// FirstEvent and LastEvent are the times of the first and last event of an archived
+	// binary log; binary logs archived before they were recorded have none
+	FirstEvent *time.Time `json:"first_event,omitempty"`
+	LastEvent  *time.Time `json:"last_event,omitempty"`
+}
+
+// EncryptionInfo describes how a backup was encrypted; it never contains secrets
+type EncryptionInfo struct {
+	Mode       string   `json:"mode"`
+	Recipients []string `json:"recipients,omitempty"`
+	KDF        string   `json:"kdf,omitempty"`
+}
@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides which backups of a database are kept. KeepLast keeps the most
+// recent backups; the grandfather-father-son rules keep the newest backup of each of the
+// most recent hours, days, weeks, months and years that have backups. MaxAge and
+// MaxTotalSize then prune old backups, but never the newest MinKeep ones.
+type RetentionPolicy struct {
+	KeepLast     int      `json:"keep_last,omitempty"`
+	Hourly       int      `json:"hourly,omitempty"`
+	Daily        int      `json:"daily,omitempty"`
+	Weekly       int      `json:"weekly,omitempty"`
+	Monthly      int      `json:"monthly,omitempty"`
+	Yearly       int      `json:"yearly,omitempty"`
+	MaxAge       Duration `json:"max_age,omitempty"`
+	MaxTotalSize ByteSize `json:"max_total_size,omitempty"`
+	MinKeep      int      `json:"min_keep,omitempty"`
+}
+
+// HasGFS reports whether any grandfather-father-son rule is configured
+func (p RetentionPolicy) HasGFS() bool {
+	return p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0 || p.Yearly > 0
+}
+
+// hasLimits reports whether an age or size limit is configured
+func (p RetentionPolicy) hasLimits() bool {
+	return p.MaxAge > 0 || p.MaxTotalSize > 0
+}
+
+// IsZero reports whether the policy has no rules, in which case nothing is pruned
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && !p.HasGFS() && !p.hasLimits()
+}
+
+// String describes the policy for log output
+func (p RetentionPolicy) String() string {
+	var rules []string
+	if p.KeepLast > 0 {
+		rules = append(rules, fmt.Sprintf("%d last", p.KeepLast))
+	}
+	for _, rule := range p.rules() {
+		if rule.count > 0 {
+			rules = append(rules, fmt.Sprintf("%d %s", rule.count, rule.name))
+		}
+	}
+	description := "keep all"
+	if len(rules) > 0 {
+		description = "keep " + strings.Join(rules, ", ")
+	}
+	if p.MaxAge > 0 {
+		description += fmt.Sprintf(", max age %s", p.MaxAge)
+	}
+	if p.MaxTotalSize > 0 {
+		description += fmt.Sprintf(", max total size %s", FormatSize(int64(p.MaxTotalSize)))
+	}
+	if p.MinKeep > 0 && p.hasLimits() {
+		description += fmt.Sprintf(", at least %d", p.MinKeep)
+	}
+	return description
+}
+
+// retentionRule keeps the newest backup of each of the latest count periods with backups
+type retentionRule struct {
+	name   string
+	count  int
+	period func(t time.Time) string
+}
+
+func (p RetentionPolicy) rules() []retentionRule {
+	return []retentionRule{
+		{"hourly", p.Hourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{"daily", p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{"yearly", p.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+// Apply splits backups into the ones to keep and the ones to prune at now, both newest first.
+// A backup selected by several rules is kept once; a zero policy keeps everything.
+func (p RetentionPolicy) Apply(backups []Backup, now time.Time) (keep []Backup, prune []Backup) {
+	sorted := make([]Backup, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+	if p.IsZero() {
+		return sorted, nil
+	}
+
+	kept := make([]bool, len(sorted))
+	if p.KeepLast <= 0 && !p.HasGFS() {
+		// Only limits are configured: start from every backup
+		for i := range kept {
+			kept[i] = true
+		}
+	}
+	for i := 0; i < p.KeepLast && i < len(sorted); i++ {
+		kept[i] = true
+	}
+	for _, rule := range p.rules() {
+		remaining := rule.count
+		lastPeriod := ""
+		for i, backup := range sorted {
+			if remaining <= 0 {
+				break
+			}
+			period := rule.period(backup.Time)
+			if i > 0 && period == lastPeriod {
+				continue
+			}
+			lastPeriod = period
+			kept[i] = true
+			remaining--
+		}
+	}
+
+	// Limits prune kept backups newest first, so the size budget goes to the
+	// newest backups; the safety floor survives any limit but still uses budget
+	var totalSize int64
+	for i, backup := range sorted {
+		if i < p.MinKeep {
+			kept[i] = true
+			totalSize += backup.Size
+			continue
+		}
+		if !kept[i] {
+			continue
+		}
+		if p.MaxAge > 0 && now.Sub(backup.Time) > time.Duration(p.MaxAge) {
+			kept[i] = false
+			continue
+		}
+		if p.MaxTotalSize > 0 && totalSize+backup.Size > int64(p.MaxTotalSize) {
+			kept[i] = false
+			continue
+		}
+		totalSize += backup.Size
+	}
+
+	for i, backup := range sorted {
+		if kept[i] {
+			keep = append(keep, backup)
+		} else {
+			prune = append(prune, backup)
+		}
+	}
+	return keep, prune
+}
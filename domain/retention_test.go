@@ -0,0 +1,271 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testTimeFormat = "2006-01-02 15:04"
+
+// testBackups creates a backup of size 10 for every timestamp
+func testBackups(t *testing.T, timestamps ...string) []Backup {
+	t.Helper()
+	backups := make([]Backup, len(timestamps))
+	for i, ts := range timestamps {
+		backupTime, err := time.ParseInLocation(testTimeFormat, ts, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups[i] = Backup{Key: "shop/shop-" + backupTime.Format(BackupTimestampFormat) + ".sql.gz", Time: backupTime, Size: 10}
+	}
+	return backups
+}
+
+// backupTimes returns the timestamps of backups in order
+func backupTimes(backups []Backup) []string {
+	times := make([]string, len(backups))
+	for i, backup := range backups {
+		times[i] = backup.Time.Format(testTimeFormat)
+	}
+	return times
+}
+
+func TestRetentionPolicyApply(t *testing.T) {
+	now, _ := time.ParseInLocation(testTimeFormat, "2026-10-16 12:00", time.UTC)
+
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		backups []string
+		keep    []string
+	}{
+		{
+			name:    "zero policy keeps everything",
+			policy:  RetentionPolicy{},
+			backups: []string{"2026-10-14 03:00", "2026-10-16 03:00", "2026-10-15 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00", "2026-10-14 03:00"},
+		},
+		{
+			name:    "keep last",
+			policy:  RetentionPolicy{KeepLast: 2},
+			backups: []string{"2026-10-12 03:00", "2026-10-16 03:00", "2026-10-14 03:00", "2026-10-15 03:00", "2026-10-13 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00"},
+		},
+		{
+			name:    "keep last with fewer backups",
+			policy:  RetentionPolicy{KeepLast: 5},
+			backups: []string{"2026-10-15 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00"},
+		},
+		{
+			name:    "hourly keeps the newest backup of each hour",
+			policy:  RetentionPolicy{Hourly: 2},
+			backups: []string{"2026-10-16 09:15", "2026-10-16 09:45", "2026-10-16 10:15", "2026-10-16 10:45", "2026-10-16 11:15", "2026-10-16 11:45"},
+			keep:    []string{"2026-10-16 11:45", "2026-10-16 10:45"},
+		},
+		{
+			name:   "daily skips days without backups",
+			policy: RetentionPolicy{Daily: 3},
+			backups: []string{
+				"2026-10-10 03:00", "2026-10-10 15:00",
+				"2026-10-13 03:00", "2026-10-13 15:00",
+				"2026-10-16 03:00",
+			},
+			keep: []string{"2026-10-16 03:00", "2026-10-13 15:00", "2026-10-10 15:00"},
+		},
+		{
+			name:   "weekly uses ISO weeks",
+			policy: RetentionPolicy{Weekly: 2},
+			// 2026-10-11 is a Sunday, 2026-10-12 a Monday
+			backups: []string{"2026-10-04 03:00", "2026-10-10 03:00", "2026-10-11 03:00", "2026-10-12 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-11 03:00"},
+		},
+		{
+			name:    "monthly",
+			policy:  RetentionPolicy{Monthly: 2},
+			backups: []string{"2026-08-31 03:00", "2026-09-01 03:00", "2026-09-30 03:00", "2026-10-01 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-09-30 03:00"},
+		},
+		{
+			name:    "yearly",
+			policy:  RetentionPolicy{Yearly: 2},
+			backups: []string{"2024-06-01 03:00", "2025-01-01 03:00", "2025-12-31 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2025-12-31 03:00"},
+		},
+		{
+			name:   "a backup selected by several rules is kept once",
+			policy: RetentionPolicy{KeepLast: 1, Hourly: 1, Daily: 2, Weekly: 2, Monthly: 2, Yearly: 2},
+			backups: []string{
+				"2025-12-31 03:00",
+				"2026-09-30 03:00",
+				"2026-10-15 03:00",
+				"2026-10-16 03:00", "2026-10-16 09:00",
+			},
+			// 10-16 09:00 is the last, hourly, daily, weekly, monthly and yearly pick
+			keep: []string{"2026-10-16 09:00", "2026-10-15 03:00", "2026-09-30 03:00", "2025-12-31 03:00"},
+		},
+		{
+			name:   "GFS rules combine with keep last",
+			policy: RetentionPolicy{KeepLast: 3, Daily: 2},
+			backups: []string{
+				"2026-10-14 03:00", "2026-10-14 15:00",
+				"2026-10-15 03:00", "2026-10-15 15:00",
+				"2026-10-16 03:00", "2026-10-16 09:00",
+			},
+			keep: []string{"2026-10-16 09:00", "2026-10-16 03:00", "2026-10-15 15:00"},
+		},
+		{
+			name:    "max age alone prunes old backups",
+			policy:  RetentionPolicy{MaxAge: Duration(48 * time.Hour)},
+			backups: []string{"2026-10-10 03:00", "2026-10-14 11:00", "2026-10-14 13:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-14 13:00"},
+		},
+		{
+			name:    "max age prunes backups kept by other rules",
+			policy:  RetentionPolicy{KeepLast: 4, Monthly: 6, MaxAge: Duration(30 * 24 * time.Hour)},
+			backups: []string{"2026-07-31 03:00", "2026-08-31 03:00", "2026-09-30 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-09-30 03:00"},
+		},
+		{
+			name:    "max total size keeps the newest backups",
+			policy:  RetentionPolicy{KeepLast: 5, MaxTotalSize: ByteSize(25)},
+			backups: []string{"2026-10-13 03:00", "2026-10-14 03:00", "2026-10-15 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00"},
+		},
+		{
+			name:    "min keep overrides max age",
+			policy:  RetentionPolicy{KeepLast: 5, MaxAge: Duration(24 * time.Hour), MinKeep: 2},
+			backups: []string{"2026-10-01 03:00", "2026-10-02 03:00", "2026-10-03 03:00"},
+			keep:    []string{"2026-10-03 03:00", "2026-10-02 03:00"},
+		},
+		{
+			name:    "min keep overrides max total size",
+			policy:  RetentionPolicy{KeepLast: 5, MaxTotalSize: ByteSize(15), MinKeep: 3},
+			backups: []string{"2026-10-13 03:00", "2026-10-14 03:00", "2026-10-15 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00", "2026-10-14 03:00"},
+		},
+		{
+			name:    "min keep is also a floor for keep last",
+			policy:  RetentionPolicy{KeepLast: 1, MinKeep: 3},
+			backups: []string{"2026-10-14 03:00", "2026-10-15 03:00", "2026-10-16 03:00"},
+			keep:    []string{"2026-10-16 03:00", "2026-10-15 03:00", "2026-10-14 03:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backups := testBackups(t, tt.backups...)
+			keep, prune := tt.policy.Apply(backups, now)
+			if got := backupTimes(keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("keep = %v, want %v", got, tt.keep)
+			}
+			if len(keep)+len(prune) != len(backups) {
+				t.Fatalf("kept %d and pruned %d of %d backups", len(keep), len(prune), len(backups))
+			}
+			seen := make(map[string]bool)
+			for _, backup := range append(keep, prune...) {
+				if seen[backup.Key] {
+					t.Fatalf("backup %s returned twice", backup.Key)
+				}
+				seen[backup.Key] = true
+			}
+			for i := 1; i < len(prune); i++ {
+				if prune[i].Time.After(prune[i-1].Time) {
+					t.Fatalf("prune is not ordered newest first: %v", backupTimes(prune))
+				}
+			}
+		})
+	}
+}
+
+// Pruned backups don't use the size budget, so smaller older backups still fit
+func TestRetentionPolicyApplySizeBudget(t *testing.T) {
+	now, _ := time.ParseInLocation(testTimeFormat, "2026-10-16 12:00", time.UTC)
+	backups := testBackups(t, "2026-10-16 03:00", "2026-10-15 03:00", "2026-10-14 03:00", "2026-10-13 03:00", "2026-10-12 03:00")
+	for i, size := range []int64{40, 50, 30, 20, 5} {
+		backups[i].Size = size
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		keep   []string
+	}{
+		{"max total size", RetentionPolicy{MaxTotalSize: ByteSize(100)}, []string{"2026-10-16 03:00", "2026-10-15 03:00", "2026-10-12 03:00"}},
+		{"min keep uses the budget", RetentionPolicy{MaxTotalSize: ByteSize(100), MinKeep: 2}, []string{"2026-10-16 03:00", "2026-10-15 03:00", "2026-10-12 03:00"}},
+		{"min keep beyond the budget", RetentionPolicy{MaxTotalSize: ByteSize(60), MinKeep: 2}, []string{"2026-10-16 03:00", "2026-10-15 03:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, _ := tt.policy.Apply(backups, now)
+			if got := backupTimes(keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("keep = %v, want %v", got, tt.keep)
+			}
+		})
+	}
+}
+
+// A backup about to be stored, as passed by a dry run, takes a slot like a stored one
+func TestRetentionPolicyApplyCountsPendingBackup(t *testing.T) {
+	now, _ := time.ParseInLocation(testTimeFormat, "2026-10-16 12:00", time.UTC)
+	stored := testBackups(t, "2026-10-14 03:00", "2026-10-15 03:00", "2026-10-16 03:00")
+	pending := testBackups(t, "2026-10-16 12:00")[0]
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		prune  []string
+	}{
+		{"keep last", RetentionPolicy{KeepLast: 3}, []string{"2026-10-14 03:00"}},
+		{"daily", RetentionPolicy{Daily: 2}, []string{"2026-10-16 03:00", "2026-10-14 03:00"}},
+		{"max total size", RetentionPolicy{MaxTotalSize: ByteSize(20)}, []string{"2026-10-15 03:00", "2026-10-14 03:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, prune := tt.policy.Apply(append(append([]Backup{}, stored...), pending), now)
+			if keep[0].Key != pending.Key {
+				t.Errorf("newest kept backup is %s, want the pending %s", keep[0].Key, pending.Key)
+			}
+			if got := backupTimes(prune); !reflect.DeepEqual(got, tt.prune) {
+				t.Errorf("prune = %v, want %v", got, tt.prune)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyString(t *testing.T) {
+	tests := []struct {
+		policy RetentionPolicy
+		want   string
+	}{
+		{RetentionPolicy{}, "keep all"},
+		{RetentionPolicy{KeepLast: 5}, "keep 5 last"},
+		{RetentionPolicy{Daily: 14, Monthly: 12}, "keep 14 daily, 12 monthly"},
+		{RetentionPolicy{KeepLast: 3, MaxAge: Duration(30 * 24 * time.Hour), MinKeep: 2}, "keep 3 last, max age 30d, at least 2"},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestParseBackupTime(t *testing.T) {
+	want := time.Date(2026, 10, 16, 3, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"shop/shop-20261016030000.sql", true},
+		{"shop/shop-20261016030000.sql.gz", true},
+		{"my-shop/my-shop-20261016030000.sql.zst.age", true},
+		{"shop/shop-latest.sql.gz", false},
+		{"shop/shop-2026101603.sql.gz", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseBackupTime(tt.name)
+		if ok != tt.ok || (ok && !got.Equal(want)) {
+			t.Errorf("ParseBackupTime(%s) = %v, %v; want ok=%v", tt.name, got, ok, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits extends time.ParseDuration with the units used for backup retention
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// ParseDuration parses durations such as "30d", "2w", "1y" or any Go duration ("36h")
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if unit, ok := durationUnits[strings.ToLower(value[len(value)-1:])]; ok {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s' (use e.g. 12h, 30d, 8w or 1y)", value)
+	}
+	return d, nil
+}
+
+// sizeUnits are binary multiples; both "GB" and "GiB" mean 1024^3 bytes
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"tib", 1 << 40}, {"gib", 1 << 30}, {"mib", 1 << 20}, {"kib", 1 << 10},
+	{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// ParseSize parses sizes such as "500GB", "1.5T" or "1048576"
+func ParseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	number, multiplier := strings.ToLower(value), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s' (use e.g. 500MB, 20GB or 1.5TB)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize formats a byte count with a binary unit (e.g. "1.5 GB")
+func FormatSize(bytes int64) string {
+	for _, unit := range []struct {
+		name  string
+		bytes int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if bytes >= unit.bytes {
+			return fmt.Sprintf("%.1f %s", float64(bytes)/float64(unit.bytes), unit.name)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// Duration is a time.Duration that is written as "30d" style strings in JSON
+type Duration time.Duration
+
+// UnmarshalJSON accepts strings parsed by ParseDuration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"30d\"")
+	}
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes whole days as "Nd" and other durations in Go notation
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// String formats the duration, preferring whole days
+func (d Duration) String() string {
+	day := 24 * time.Hour
+	if d > 0 && time.Duration(d)%day == 0 {
+		return fmt.Sprintf("%dd", time.Duration(d)/day)
+	}
+	return time.Duration(d).String()
+}
+
+// ByteSize is a byte count that is written as "500GB" style strings in JSON
+type ByteSize int64
+
+// UnmarshalJSON accepts strings parsed by ParseSize or plain numbers of bytes
+func (s *ByteSize) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("size must be a string like \"500GB\" or a number of bytes")
+		}
+		*s = ByteSize(n)
+		return nil
+	}
+	parsed, err := ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = ByteSize(parsed)
+	return nil
+}
+
+// MarshalJSON writes the size as a number of bytes
+func (s ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int64(s))
+}
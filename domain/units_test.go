@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, true},
+		{"30d", 30 * 24 * time.Hour, true},
+		{"2W", 14 * 24 * time.Hour, true},
+		{"1y", 365 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"1.5d", 0, false},
+		{"-3d", 0, false},
+		{"forever", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"", 0, true},
+		{"1048576", 1 << 20, true},
+		{"500MB", 500 << 20, true},
+		{"20 GiB", 20 << 30, true},
+		{"1.5T", 3 << 39, true},
+		{"512b", 512, true},
+		{"-1GB", 0, false},
+		{"lots", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:            "512 B",
+		1536:           "1.5 KB",
+		500 << 20:      "500.0 MB",
+		3 << 39:        "1.5 TB",
+		(20 << 30) + 1: "20.0 GB",
+	}
+	for bytes, want := range tests {
+		if got := FormatSize(bytes); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", bytes, got, want)
+		}
+	}
+}
+
+func TestRetentionPolicyJSON(t *testing.T) {
+	var policy RetentionPolicy
+	if err := json.Unmarshal([]byte(`{"keep_last":3,"max_age":"30d","max_total_size":"2GB"}`), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.MaxAge != Duration(30*24*time.Hour) || policy.MaxTotalSize != ByteSize(2<<30) {
+		t.Errorf("unmarshalled %+v", policy)
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again RetentionPolicy
+	if err := json.Unmarshal(data, &again); err != nil || again != policy {
+		t.Errorf("round trip of %s = %+v, %v", data, again, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"max_age":30}`), &policy); err == nil {
+		t.Error("numeric max_age: expected an error")
+	}
+}
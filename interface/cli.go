@@ -2,20 +2,33 @@ package cli
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/magicstack-llp/db-backup-go/app"
 	"github.com/magicstack-llp/db-backup-go/data"
+	"github.com/magicstack-llp/db-backup-go/domain"
 	"github.com/spf13/cobra"
 )
 
+// Version, GitCommit and BuildTime describe the running binary; they are set by main
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
 var (
 	configPath     string
 	connectionName string
@@ -23,8 +36,44 @@ var (
 	storageType    string
 	backupDir      string
 	mysqldumpPath  string
+	dumpMethod     string
+	backupType     string
 	compress       bool
 	noCompress     bool
+	compression    string
+	compressLevel  int
+	databaseName   string
+	backupName     string
+	latestBackup   bool
+	targetDatabase string
+	mysqlPath      string
+	forceRestore   bool
+	encryption     string
+	recipients     []string
+	identityFile   string
+	keyFile        string
+	maxAge         string
+	maxTotalSize   string
+	minKeep        int
+	dryRun         bool
+	outputPath     string
+	keepCompressed bool
+	since          string
+	jsonOutput     bool
+	assumeYes      bool
+	verifyAll      bool
+	restoreTest    bool
+	sandboxName    string
+	assertionsDir  string
+	parallel       int
+	backupAll      bool
+	backupNames    []string
+	parallelConns  int
+	targetDir      string
+	copyBack       bool
+	restoreTo      string
+	flushBinlogs   bool
+	binlogInterval time.Duration
 )
 
 // defaultConfigPath returns the default path for .env file
@@ -124,22 +173,40 @@ func initConfigInteractive(configPath string) error {
 	fmt.Println("Setting up storage and global configuration...")
 	fmt.Println("(Database connections are managed separately with 'add' command)")
 
-	backupDriver := promptString("Backup driver (local/s3)", existing["BACKUP_DRIVER"])
+	backupDriver := promptString("Backup driver (local/s3/sftp)", existing["BACKUP_DRIVER"])
 	if backupDriver == "" {
 		backupDriver = "local"
 	}
 	backupDriver = strings.ToLower(backupDriver)
 
 	var backupDir, s3Bucket, s3Path, awsAccessKeyID, awsSecretAccessKey string
+	var sftpHost, sftpPort, sftpUser, sftpKeyPath, sftpPath string
+	var s3Endpoint, s3Region string
+	var s3ForcePathStyle bool
 
 	if backupDriver == "local" {
 		backupDir = promptString("Local backup directory", existing["BACKUP_DIR"])
+	} else if backupDriver == "sftp" {
+		sftpHost = promptString("SFTP host", existing["SFTP_HOST"])
+		sftpPort = existing["SFTP_PORT"]
+		if sftpPort == "" {
+			sftpPort = "22"
+		}
+		sftpPort = promptString("SFTP port", sftpPort)
+		sftpUser = promptString("SFTP user", existing["SFTP_USER"])
+		sftpKeyPath = promptString("SFTP key path", existing["SFTP_KEY_PATH"])
+		sftpPath = promptString("Remote backup directory", existing["SFTP_PATH"])
 	} else {
 		s3Bucket = promptString("S3 bucket name", existing["S3_BUCKET"])
 		s3Path = promptString("S3 base path", existing["S3_PATH"])
 		if s3Path == "" {
 			s3Path = "backups"
 		}
+		s3Endpoint = promptString("S3 endpoint URL (leave empty for AWS)", existing["S3_ENDPOINT"])
+		s3Region = promptString("S3 region (leave empty for AWS default)", existing["S3_REGION"])
+		if s3Endpoint != "" {
+			s3ForcePathStyle = promptBool("Use path-style addressing (required by most MinIO/Ceph setups)?", existing["S3_FORCE_PATH_STYLE"] == "true")
+		}
 		awsAccessKeyID = promptString("AWS Access Key ID", existing["AWS_ACCESS_KEY_ID"])
 		fmt.Print("AWS Secret Access Key: ")
 		reader := bufio.NewReader(os.Stdin)
@@ -158,14 +225,52 @@ func initConfigInteractive(configPath string) error {
 	}
 	retentionCount := promptInt("Retention count (how many backups to keep)", retentionDefault)
 
+	compressionDefault := existing["COMPRESSION"]
+	if compressionDefault == "" {
+		compressionDefault = "gzip"
+	}
+	compressionCodec := strings.ToLower(promptString("Compression (gzip/zstd/xz/lz4/none)", compressionDefault))
+
+	encryptionDefault := existing["ENCRYPTION"]
+	if encryptionDefault == "" {
+		encryptionDefault = "none"
+	}
+	encryptionMode := strings.ToLower(promptString("Encryption (age/gpg/aes/none)", encryptionDefault))
+	var encryptionRecipients, encryptionKeyFile string
+	if encryptionMode == "aes" {
+		encryptionKeyFile = promptString("Encryption key file (leave empty to use ENCRYPTION_PASSPHRASE)", existing["ENCRYPTION_KEY_FILE"])
+	} else if encryptionMode != "none" {
+		encryptionRecipients = promptString("Encryption recipients (public keys or key files, comma separated)", existing["ENCRYPTION_RECIPIENTS"])
+	}
+
 	// Write .env file
 	lines := []string{
 		fmt.Sprintf("BACKUP_DRIVER=%s", backupDriver),
 		fmt.Sprintf("RETENTION_COUNT=%d", retentionCount),
+		fmt.Sprintf("COMPRESSION=%s", compressionCodec),
+	}
+	if encryptionMode == "aes" {
+		lines = append(lines, fmt.Sprintf("ENCRYPTION=%s", encryptionMode))
+		if encryptionKeyFile != "" {
+			lines = append(lines, fmt.Sprintf("ENCRYPTION_KEY_FILE=%s", encryptionKeyFile))
+		}
+	} else if encryptionMode != "none" {
+		lines = append(lines,
+			fmt.Sprintf("ENCRYPTION=%s", encryptionMode),
+			fmt.Sprintf("ENCRYPTION_RECIPIENTS=%s", encryptionRecipients),
+		)
 	}
 
 	if backupDriver == "local" {
 		lines = append(lines, fmt.Sprintf("BACKUP_DIR=%s", backupDir))
+	} else if backupDriver == "sftp" {
+		lines = append(lines,
+			fmt.Sprintf("SFTP_HOST=%s", sftpHost),
+			fmt.Sprintf("SFTP_PORT=%s", sftpPort),
+			fmt.Sprintf("SFTP_USER=%s", sftpUser),
+			fmt.Sprintf("SFTP_KEY_PATH=%s", sftpKeyPath),
+			fmt.Sprintf("SFTP_PATH=%s", sftpPath),
+		)
 	} else {
 		lines = append(lines,
 			fmt.Sprintf("S3_BUCKET=%s", s3Bucket),
@@ -173,6 +278,15 @@ func initConfigInteractive(configPath string) error {
 			fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", awsAccessKeyID),
 			fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", awsSecretAccessKey),
 		)
+		if s3Endpoint != "" {
+			lines = append(lines, fmt.Sprintf("S3_ENDPOINT=%s", s3Endpoint))
+		}
+		if s3Region != "" {
+			lines = append(lines, fmt.Sprintf("S3_REGION=%s", s3Region))
+		}
+		if s3ForcePathStyle {
+			lines = append(lines, "S3_FORCE_PATH_STYLE=true")
+		}
 	}
 
 	content := strings.Join(lines, "\n") + "\n"
@@ -324,9 +438,8 @@ func installCrontab(lines []string) error {
 	return nil
 }
 
-// backupCmd handles the backup command
-func backupCmd(cmd *cobra.Command, args []string) error {
-	// Resolve config path
+// loadConfig resolves, ensures and loads the .env config file
+func loadConfig() error {
 	if configPath == "" {
 		configPath = os.Getenv("DATABASE_BACKUP_CONFIG")
 		if configPath == "" {
@@ -338,25 +451,23 @@ func backupCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Load .env file
 	if err := godotenv.Load(configPath); err != nil {
 		fmt.Printf("Warning: failed to load .env file: %v\n", err)
 	}
 
-	// Load connection
-	connManager, err := data.NewConnectionManager("")
-	if err != nil {
-		return fmt.Errorf("failed to create connection manager: %w", err)
-	}
+	return nil
+}
 
+// selectConnection returns the connection named by --connection, or picks one
+func selectConnection(connManager *data.ConnectionManager) (*data.Connection, error) {
 	if connectionName == "" {
 		connections, err := connManager.ListConnections()
 		if err != nil {
-			return fmt.Errorf("failed to list connections: %w", err)
+			return nil, fmt.Errorf("failed to list connections: %w", err)
 		}
 
 		if len(connections) == 0 {
-			return fmt.Errorf("no connections found. Use 'db-backup add' to add a connection")
+			return nil, fmt.Errorf("no connections found. Use 'db-backup add' to add a connection")
 		} else if len(connections) == 1 {
 			connectionName = connections[0]
 			fmt.Printf("Using connection: %s\n", connectionName)
@@ -367,7 +478,7 @@ func backupCmd(cmd *cobra.Command, args []string) error {
 			}
 			choice := promptInt("Select connection", 1)
 			if choice < 1 || choice > len(connections) {
-				return fmt.Errorf("invalid selection")
+				return nil, fmt.Errorf("invalid selection")
 			}
 			connectionName = connections[choice-1]
 		}
@@ -375,107 +486,641 @@ func backupCmd(cmd *cobra.Command, args []string) error {
 
 	conn, err := connManager.GetConnection(connectionName)
 	if err != nil {
-		return fmt.Errorf("connection '%s' not found: %w", connectionName, err)
+		return nil, fmt.Errorf("connection '%s' not found: %w", connectionName, err)
 	}
+	return conn, nil
+}
 
-	// Determine storage type
-	if storageType == "" {
-		localFlag, _ := cmd.Flags().GetBool("local")
-		s3Flag, _ := cmd.Flags().GetBool("s3")
+// newDatabaseGateway creates a database gateway for a connection
+func newDatabaseGateway(conn *data.Connection) (*data.DatabaseGateway, error) {
+	cfg := data.EngineConfig{
+		Name:              firstNonEmpty(conn.Engine, os.Getenv("DB_ENGINE")),
+		IncludePostgresDB: conn.PgIncludePostgresDB,
+	}
+	// Tool paths and globals only apply to the engine they belong to
+	switch strings.ToLower(cfg.Name) {
+	case "postgres", "postgresql":
+		cfg.DumpPath, cfg.ClientPath = conn.PgDumpPath, conn.PsqlPath
+		cfg.Globals = conn.PgGlobals
+	default:
+		cfg.DumpPath = firstNonEmpty(mysqldumpPath, conn.MysqldumpPath)
+		cfg.ClientPath = firstNonEmpty(mysqlPath, conn.MysqlPath)
+		cfg.Globals = conn.MariaDBUsers
+		cfg.DumpMethod = firstNonEmpty(dumpMethod, conn.DumpMethod)
+		cfg.PhysicalPath = conn.XtrabackupPath
+		cfg.Binlog, cfg.BinlogPath = conn.BinlogArchive, conn.MysqlbinlogPath
+	}
+	engine, err := data.NewEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		if localFlag {
-			storageType = "local"
-		} else if s3Flag {
-			storageType = "s3"
-		} else if conn.StorageDriver != "" {
-			storageType = strings.ToLower(conn.StorageDriver)
-		} else {
-			storageType = strings.ToLower(os.Getenv("BACKUP_DRIVER"))
-		}
+	return data.NewDatabaseGateway(engine,
+		conn.Host, conn.Port, conn.User, conn.Password,
+		conn.ExcludedDBs,
+		conn.SSHHost, conn.SSHPort, conn.SSHUser, conn.SSHKeyPath,
+		conn.BastionHost, conn.BastionPort, conn.BastionUser, conn.BastionKeyPath,
+	), nil
+}
+
+// resolveStorageDriver determines the storage driver from flags, connection and .env
+func resolveStorageDriver(cmd *cobra.Command, conn *data.Connection) string {
+	if storageType != "" {
+		return strings.ToLower(storageType)
+	}
+
+	localFlag, _ := cmd.Flags().GetBool("local")
+	s3Flag, _ := cmd.Flags().GetBool("s3")
+
+	if localFlag {
+		return "local"
+	} else if s3Flag {
+		return "s3"
+	} else if conn.StorageDriver != "" {
+		return strings.ToLower(conn.StorageDriver)
+	}
+	return strings.ToLower(os.Getenv("BACKUP_DRIVER"))
+}
+
+// newStorageGateway creates the storage gateway selected for a connection
+func newStorageGateway(cmd *cobra.Command, name string, conn *data.Connection) (*data.StorageGateway, error) {
+	driver := resolveStorageDriver(cmd, conn)
+	if driver == "" {
+		return nil, fmt.Errorf("please specify a storage type: --storage, --local or --s3, set storage_driver in connection, or set BACKUP_DRIVER in .env")
+	}
+
+	// Objects are tagged with their connection and host (the S3 driver adds the database)
+	tags := map[string]string{"connection": name, "host": conn.Host}
+	for key, value := range conn.S3Tags {
+		tags[key] = value
+	}
+
+	cfg := data.StorageConfig{
+		Driver:                 driver,
+		Path:                   conn.Path,
+		S3Bucket:               conn.S3Bucket,
+		S3Endpoint:             conn.S3Endpoint,
+		S3Region:               conn.S3Region,
+		S3ForcePathStyle:       conn.S3ForcePathStyle,
+		S3ServerSideEncryption: conn.S3SSE,
+		S3KMSKeyID:             conn.S3KMSKeyID,
+		S3StorageClass:         conn.S3StorageClass,
+		S3ChecksumAlgorithm:    conn.S3ChecksumAlgorithm,
+		S3Tags:                 tags,
+		S3Metadata:             conn.S3Metadata,
+		S3ObjectLockMode:       conn.S3ObjectLockMode,
+		S3ObjectLockRetention:  conn.S3ObjectLockRetention,
+		S3LegalHold:            conn.S3LegalHold,
+		LocalImmutableMinAge:   conn.ImmutableMinAge,
+		SFTPHost:               conn.SFTPHost,
+		SFTPPort:               conn.SFTPPort,
+		SFTPUser:               conn.SFTPUser,
+		SFTPKeyPath:            conn.SFTPKeyPath,
+		SFTPBastionHost:        conn.SFTPBastionHost,
+		SFTPBastionPort:        conn.SFTPBastionPort,
+		SFTPBastionUser:        conn.SFTPBastionUser,
+		SFTPBastionKeyPath:     conn.SFTPBastionKeyPath,
+	}
+	if driver == "local" && backupDir != "" {
+		cfg.Path = backupDir
+	}
+
+	storage, err := data.NewStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage gateway: %w", err)
 	}
 
-	// Determine retention count
-	retentionCount := retention
-	if retentionCount == 0 {
-		if val := os.Getenv("RETENTION_COUNT"); val != "" {
-			if parsed, err := strconv.Atoi(val); err == nil {
-				retentionCount = parsed
+	return data.NewStorageGateway(storage), nil
+}
+
+// resolveRetention builds the retention policy from .env, the connection and the retention flags.
+// Without any rule the last 5 backups are kept; once GFS rules or limits are configured,
+// RETENTION_COUNT only applies when it is set explicitly. At least one backup is always kept.
+func resolveRetention(conn *data.Connection) (domain.RetentionPolicy, error) {
+	var policy domain.RetentionPolicy
+	envRules := []struct {
+		name  string
+		value *int
+	}{
+		{"RETENTION_COUNT", &policy.KeepLast},
+		{"RETENTION_HOURLY", &policy.Hourly},
+		{"RETENTION_DAILY", &policy.Daily},
+		{"RETENTION_WEEKLY", &policy.Weekly},
+		{"RETENTION_MONTHLY", &policy.Monthly},
+		{"RETENTION_YEARLY", &policy.Yearly},
+		{"RETENTION_MIN_KEEP", &policy.MinKeep},
+	}
+	for _, rule := range envRules {
+		if val := os.Getenv(rule.name); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil || parsed < 0 {
+				return policy, fmt.Errorf("invalid %s '%s'", rule.name, val)
 			}
-		}
-		if retentionCount == 0 {
-			retentionCount = 5
+			*rule.value = parsed
 		}
 	}
+	age, err := domain.ParseDuration(firstNonEmpty(maxAge, os.Getenv("RETENTION_MAX_AGE")))
+	if err != nil {
+		return policy, fmt.Errorf("invalid max age: %w", err)
+	}
+	policy.MaxAge = domain.Duration(age)
+	size, err := domain.ParseSize(firstNonEmpty(maxTotalSize, os.Getenv("RETENTION_MAX_TOTAL_SIZE")))
+	if err != nil {
+		return policy, fmt.Errorf("invalid max total size: %w", err)
+	}
+	policy.MaxTotalSize = domain.ByteSize(size)
 
-	// Determine compression
-	shouldCompress := compress
-	if noCompress {
-		shouldCompress = false
+	// A connection's retention replaces the global policy
+	if conn.Retention != nil {
+		policy = *conn.Retention
+	}
+	if retention > 0 {
+		policy.KeepLast = retention
+	}
+	if maxAge != "" {
+		policy.MaxAge = domain.Duration(age)
 	}
-	if !compress && !noCompress {
-		shouldCompress = true // default
+	if maxTotalSize != "" {
+		policy.MaxTotalSize = domain.ByteSize(size)
 	}
+	if minKeep > 0 {
+		policy.MinKeep = minKeep
+	}
+	if policy.IsZero() {
+		policy.KeepLast = 5
+	}
+	if policy.MinKeep == 0 {
+		policy.MinKeep = 1
+	}
+	return policy, nil
+}
 
-	// Create database gateway
-	dbGateway := data.NewDatabaseGateway(
-		conn.Host, conn.Port, conn.User, conn.Password,
-		conn.MysqldumpPath, conn.ExcludedDBs,
-		conn.SSHHost, conn.SSHPort, conn.SSHUser, conn.SSHKeyPath,
-		conn.BastionHost, conn.BastionPort, conn.BastionUser, conn.BastionKeyPath,
-	)
-	defer dbGateway.Close()
+// resolveCompression determines the codec and level from flags, connection and .env
+func resolveCompression(cmd *cobra.Command, conn *data.Connection) (data.Codec, int, error) {
+	codecName := compression
+	if codecName == "" {
+		if noCompress || (cmd.Flags().Changed("compress") && !compress) {
+			codecName = "none"
+		} else if conn.Compression != "" {
+			codecName = conn.Compression
+		} else if env := os.Getenv("COMPRESSION"); env != "" {
+			codecName = env
+		} else {
+			codecName = "gzip"
+		}
+	}
 
-	// Create storage gateway
-	var storageGateway *data.StorageGateway
-	var effectiveBackupDir, effectiveS3Bucket, effectiveS3Path string
+	codec, err := data.GetCodec(codecName)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	if storageType == "local" {
-		effectiveBackupDir = backupDir
-		if effectiveBackupDir == "" {
-			effectiveBackupDir = conn.Path
+	level := compressLevel
+	if level == 0 {
+		level = conn.CompressionLevel
+	}
+	if level == 0 {
+		if val := os.Getenv("COMPRESSION_LEVEL"); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid COMPRESSION_LEVEL '%s'", val)
+			}
+			level = parsed
 		}
-		if effectiveBackupDir == "" {
-			effectiveBackupDir = os.Getenv("BACKUP_DIR")
+	}
+
+	return codec, level, nil
+}
+
+// resolveParallel determines how many databases are backed up at once
+func resolveParallel(conn *data.Connection) (int, error) {
+	n := parallel
+	if n == 0 {
+		n = conn.Parallel
+	}
+	if n == 0 {
+		if val := os.Getenv("BACKUP_PARALLEL"); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil {
+				return 0, fmt.Errorf("invalid BACKUP_PARALLEL '%s'", val)
+			}
+			n = parsed
 		}
-		if effectiveBackupDir == "" {
-			return fmt.Errorf("please specify --backup-dir, set path in connection, or set BACKUP_DIR in .env")
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("parallel must be at least 1, got %d", n)
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n, nil
+}
+
+// resolveBackupType determines whether databases are dumped or the server is backed up
+// physically
+func resolveBackupType(conn *data.Connection) (string, error) {
+	backupType := strings.ToLower(firstNonEmpty(backupType, conn.BackupType, os.Getenv("BACKUP_TYPE"), domain.BackupTypeLogical))
+	switch backupType {
+	case domain.BackupTypeLogical, domain.BackupTypePhysical:
+		return backupType, nil
+	}
+	return "", fmt.Errorf("unknown backup type '%s' (available: %s, %s)", backupType, domain.BackupTypeLogical, domain.BackupTypePhysical)
+}
+
+// resolveEncryptionConfig collects encryption keys from flags, connection and .env
+func resolveEncryptionConfig(conn *data.Connection) data.EncryptionConfig {
+	cfg := data.EncryptionConfig{
+		Recipients:   recipients,
+		IdentityFile: firstNonEmpty(identityFile, conn.IdentityFile, os.Getenv("ENCRYPTION_IDENTITY")),
+		Passphrase:   os.Getenv("ENCRYPTION_PASSPHRASE"),
+		KeyFile:      firstNonEmpty(keyFile, conn.KeyFile, os.Getenv("ENCRYPTION_KEY_FILE")),
+		KDF:          os.Getenv("ENCRYPTION_KDF"),
+	}
+	if len(cfg.Recipients) == 0 {
+		cfg.Recipients = conn.Recipients
+	}
+	if len(cfg.Recipients) == 0 {
+		if env := os.Getenv("ENCRYPTION_RECIPIENTS"); env != "" {
+			for _, recipient := range strings.Split(env, ",") {
+				if recipient = strings.TrimSpace(recipient); recipient != "" {
+					cfg.Recipients = append(cfg.Recipients, recipient)
+				}
+			}
 		}
+	}
+	return cfg
+}
 
-		storageGateway, err = data.NewStorageGateway(effectiveBackupDir, "", "", "", "")
-		if err != nil {
-			return fmt.Errorf("failed to create storage gateway: %w", err)
+// resolveEncryption determines the encryption mode and keys used for new backups;
+// a nil Encryption means backups are stored unencrypted
+func resolveEncryption(conn *data.Connection) (data.Encryption, data.EncryptionConfig, error) {
+	cfg := resolveEncryptionConfig(conn)
+	name := firstNonEmpty(encryption, conn.Encryption, os.Getenv("ENCRYPTION"))
+	if name == "" || strings.EqualFold(name, "none") {
+		return nil, cfg, nil
+	}
+	enc, err := data.GetEncryption(name)
+	if err != nil {
+		return nil, cfg, err
+	}
+	if err := enc.Validate(cfg); err != nil {
+		return nil, cfg, err
+	}
+	return enc, cfg, nil
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
 		}
-	} else if storageType == "s3" {
-		effectiveS3Bucket = conn.S3Bucket
-		if effectiveS3Bucket == "" {
-			effectiveS3Bucket = os.Getenv("S3_BUCKET")
+	}
+	return ""
+}
+
+// backupCmd handles the backup command
+func backupCmd(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	// Load connection
+	connManager, err := data.NewConnectionManager("")
+	if err != nil {
+		return fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	if backupAll || len(backupNames) > 0 {
+		return backupConnections(cmd, connManager)
+	}
+
+	conn, err := selectConnection(connManager)
+	if err != nil {
+		return err
+	}
+	return backupConnection(cmd, connectionName, conn)
+}
+
+// connectionResult is the outcome of backing up one connection
+type connectionResult struct {
+	name     string
+	duration time.Duration
+	err      error
+}
+
+// backupConnections backs up the connections selected with --all or --connections,
+// each with its own settings and storage, and fails if any of them failed
+func backupConnections(cmd *cobra.Command, connManager *data.ConnectionManager) error {
+	if connectionName != "" {
+		return fmt.Errorf("--connection can't be combined with --all or --connections")
+	}
+	if backupAll && len(backupNames) > 0 {
+		return fmt.Errorf("use either --all or --connections, not both")
+	}
+
+	names := backupNames
+	if backupAll {
+		var err error
+		names, err = connManager.ListConnections()
+		if err != nil {
+			return fmt.Errorf("failed to list connections: %w", err)
 		}
-		if effectiveS3Bucket == "" {
-			return fmt.Errorf("please set s3_bucket in connection, set S3_BUCKET in .env, or use --s3 with proper configuration")
+		if len(names) == 0 {
+			return fmt.Errorf("no connections found. Use 'db-backup add' to add a connection")
 		}
+		sort.Strings(names)
+	}
 
-		effectiveS3Path = conn.Path
-		if effectiveS3Path == "" {
-			effectiveS3Path = os.Getenv("S3_PATH")
+	// Look every connection up first so that a typo fails before anything is backed up
+	conns := make([]*data.Connection, len(names))
+	for i, name := range names {
+		conn, err := connManager.GetConnection(name)
+		if err != nil {
+			return err
 		}
-		if effectiveS3Path == "" {
-			effectiveS3Path = "backups"
+		conns[i] = conn
+	}
+	if err := checkStorageOverlap(cmd, names, conns); err != nil {
+		return err
+	}
+
+	workers := parallelConns
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	results := make([]connectionResult, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				name := names[idx]
+				fmt.Printf("==> Backing up connection %s\n", name)
+				start := time.Now()
+				err := backupConnection(cmd, name, conns[idx])
+				if err != nil {
+					fmt.Printf("Error backing up connection %s: %v\n", name, err)
+				}
+				results[idx] = connectionResult{name: name, duration: time.Since(start), err: err}
+			}
+		}()
+	}
+	for idx := range names {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Println("\nConnections:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.name)
+			fmt.Fprintf(tw, "FAILED\t%s\t%s\t%v\n", result.name, result.duration.Round(time.Second), result.err)
+			continue
 		}
+		fmt.Fprintf(tw, "OK\t%s\t%s\n", result.name, result.duration.Round(time.Second))
+	}
+	tw.Flush()
 
-		awsAccessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
-		awsSecretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if len(failed) > 0 {
+		return fmt.Errorf("backup failed for %d of %d connection(s): %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
 
-		storageGateway, err = data.NewStorageGateway("", effectiveS3Bucket, effectiveS3Path, awsAccessKeyID, awsSecretAccessKey)
+// checkStorageOverlap fails if two connections store their backups in the same place.
+// Backups are stored per database, so the connections would list and prune each
+// other's backups of databases with the same name.
+func checkStorageOverlap(cmd *cobra.Command, names []string, conns []*data.Connection) error {
+	roots := make([]string, len(names))
+	for i, name := range names {
+		storageGateway, err := newStorageGateway(cmd, name, conns[i])
 		if err != nil {
-			return fmt.Errorf("failed to create storage gateway: %w", err)
+			// Reported when the connection is backed up
+			continue
+		}
+		roots[i] = strings.TrimSuffix(storageGateway.Location(""), "/") + "/"
+		storageGateway.Close()
+		if resolveStorageDriver(cmd, conns[i]) == "s3" {
+			// The same bucket name on another endpoint is another bucket
+			roots[i] = conns[i].S3Endpoint + " " + roots[i]
+		}
+	}
+	for i := range roots {
+		for j := i + 1; j < len(roots); j++ {
+			if roots[i] == "" || roots[j] == "" {
+				continue
+			}
+			if strings.HasPrefix(roots[i], roots[j]) || strings.HasPrefix(roots[j], roots[i]) {
+				return fmt.Errorf("connections %s and %s store their backups in the same place; give each its own path or bucket so that they don't prune each other's backups", names[i], names[j])
+			}
 		}
-	} else {
-		return fmt.Errorf("please specify a storage type: --local or --s3, set storage_driver in connection, or set BACKUP_DRIVER in .env")
 	}
+	return nil
+}
+
+// backupConnection backs up the databases of one connection
+func backupConnection(cmd *cobra.Command, name string, conn *data.Connection) error {
+	// Determine retention policy
+	policy, err := resolveRetention(conn)
+	if err != nil {
+		return err
+	}
+
+	// Determine compression
+	codec, level, err := resolveCompression(cmd, conn)
+	if err != nil {
+		return err
+	}
+
+	// Determine encryption
+	enc, encCfg, err := resolveEncryption(conn)
+	if err != nil {
+		return err
+	}
+
+	workers, err := resolveParallel(conn)
+	if err != nil {
+		return err
+	}
+
+	backupType, err := resolveBackupType(conn)
+	if err != nil {
+		return err
+	}
+
+	// Create database gateway
+	dbGateway, err := newDatabaseGateway(conn)
+	if err != nil {
+		return err
+	}
+	defer dbGateway.Close()
+
+	// Create storage gateway
+	storageGateway, err := newStorageGateway(cmd, name, conn)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
 
 	// Create use case and execute
 	useCase := app.NewBackupUseCase(dbGateway, storageGateway)
-	return useCase.Execute(retentionCount, effectiveBackupDir, effectiveS3Bucket, effectiveS3Path, shouldCompress)
+	return useCase.Execute(app.BackupOptions{
+		Retention:        policy,
+		Codec:            codec,
+		CompressionLevel: level,
+		Encryption:       enc,
+		EncryptionConfig: encCfg,
+		DryRun:           dryRun,
+		Parallel:         workers,
+		Type:             backupType,
+		Connection:       name,
+		ToolVersion:      Version,
+	})
+}
+
+// restoreCmd handles the restore command
+func restoreCmd(cmd *cobra.Command, args []string) error {
+	if databaseName == "" {
+		return fmt.Errorf("please specify the database to restore with --database")
+	}
+	if databaseName == data.BinlogDatabase {
+		return fmt.Errorf("binary logs are applied by restoring a database with --to")
+	}
+	if restoreTo != "" && (backupName != "" || latestBackup) {
+		return fmt.Errorf("--to picks the backup itself and can't be combined with --backup or --latest")
+	}
+	if backupName == "" && !latestBackup && restoreTo == "" {
+		return fmt.Errorf("please specify --backup <name>, --latest or --to <time>")
+	}
+	if backupName != "" && latestBackup {
+		return fmt.Errorf("--backup and --latest are mutually exclusive")
+	}
+	var pointInTime time.Time
+	if restoreTo != "" {
+		t, err := parseRestoreTime(restoreTo)
+		if err != nil {
+			return err
+		}
+		pointInTime = t
+	}
+	physical := databaseName == data.PhysicalDatabase
+	if physical && restoreTo != "" {
+		return fmt.Errorf("physical backups can't be restored to a point in time")
+	}
+	if physical && targetDir == "" {
+		return fmt.Errorf("please specify the directory to restore the physical backup into with --target-dir")
+	}
+	if !physical && (targetDir != "" || copyBack) {
+		return fmt.Errorf("--target-dir and --copy-back only apply to physical backups (--database %s)", data.PhysicalDatabase)
+	}
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	connManager, err := data.NewConnectionManager("")
+	if err != nil {
+		return fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	conn, err := selectConnection(connManager)
+	if err != nil {
+		return err
+	}
+
+	dbGateway, err := newDatabaseGateway(conn)
+	if err != nil {
+		return err
+	}
+	defer dbGateway.Close()
+
+	storageGateway, err := newStorageGateway(cmd, connectionName, conn)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	useCase := app.NewRestoreUseCase(dbGateway, storageGateway, resolveEncryptionConfig(conn))
+	if physical {
+		return useCase.ExecutePhysical(backupName, targetDir, copyBack)
+	}
+	if !pointInTime.IsZero() {
+		return useCase.ExecutePointInTime(databaseName, targetDatabase, pointInTime, forceRestore)
+	}
+	return useCase.Execute(databaseName, targetDatabase, backupName, forceRestore)
+}
+
+// parseRestoreTime parses the --to value of restore as local time
+func parseRestoreTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --to value '%s' (use a time like \"2006-01-02 15:04:05\")", value)
+}
+
+// binlogCmd handles the binlog command
+func binlogCmd(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	connManager, err := data.NewConnectionManager("")
+	if err != nil {
+		return fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	conn, err := selectConnection(connManager)
+	if err != nil {
+		return err
+	}
+
+	codec, level, err := resolveCompression(cmd, conn)
+	if err != nil {
+		return err
+	}
+
+	enc, encCfg, err := resolveEncryption(conn)
+	if err != nil {
+		return err
+	}
+
+	dbGateway, err := newDatabaseGateway(conn)
+	if err != nil {
+		return err
+	}
+	defer dbGateway.Close()
+
+	storageGateway, err := newStorageGateway(cmd, connectionName, conn)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	useCase := app.NewBinlogUseCase(dbGateway, storageGateway)
+	return useCase.Execute(app.BinlogOptions{
+		BackupOptions: app.BackupOptions{
+			Codec:            codec,
+			CompressionLevel: level,
+			Encryption:       enc,
+			EncryptionConfig: encCfg,
+			Connection:       connectionName,
+			ToolVersion:      Version,
+		},
+		Flush:    flushBinlogs,
+		Interval: binlogInterval,
+	})
 }
 
 // addCmd handles the add command
@@ -504,22 +1149,41 @@ func addCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get connection details
-	host := promptString("MySQL host", getHost(existing))
+	engine := strings.ToLower(promptString("Database engine (mysql/mariadb/postgres)", getEngine(existing)))
+	if engine == "postgresql" {
+		engine = "postgres"
+	}
+	if _, err := data.NewEngine(data.EngineConfig{Name: engine}); err != nil {
+		return err
+	}
+	label, defaultPort, defaultUser := "MySQL", 3306, "root"
+	switch engine {
+	case "mariadb":
+		label = "MariaDB"
+	case "postgres":
+		label, defaultPort, defaultUser = "PostgreSQL", 5432, "postgres"
+	}
+
+	host := promptString(label+" host", getHost(existing))
 	if host == "" {
 		host = "localhost"
 	}
 
-	port := promptInt("MySQL port", getPort(existing))
+	port := defaultPort
+	if existing != nil && existing.Port != 0 {
+		port = existing.Port
+	}
+	port = promptInt(label+" port", port)
 	if port == 0 {
-		port = 3306
+		port = defaultPort
 	}
 
-	user := promptString("MySQL user", getUser(existing))
+	user := promptString(label+" user", getUser(existing))
 	if user == "" {
-		user = "root"
+		user = defaultUser
 	}
 
-	fmt.Print("MySQL password: ")
+	fmt.Print(label + " password: ")
 	reader := bufio.NewReader(os.Stdin)
 	password, _ := reader.ReadString('\n')
 	password = strings.TrimSpace(password)
@@ -527,15 +1191,46 @@ func addCmd(cmd *cobra.Command, args []string) error {
 		password = existing.Password
 	}
 
-	mysqldumpPath := promptString("mysqldump path", existing.MysqldumpPath)
-	if mysqldumpPath == "" {
-		if path, err := exec.LookPath("mysqldump"); err == nil {
-			mysqldumpPath = path
-		} else {
-			mysqldumpPath = "/opt/homebrew/opt/mysql-client/bin/mysqldump"
+	var mysqldumpPath, pgDumpPath, connDumpMethod string
+	var pgGlobals, mariadbUsers bool
+	if engine == "postgres" {
+		if existing != nil {
+			pgDumpPath, pgGlobals = existing.PgDumpPath, existing.PgGlobals
 		}
-		if !promptBool(fmt.Sprintf("Use mysqldump at '%s'?", mysqldumpPath), true) {
-			mysqldumpPath = promptString("mysqldump path", mysqldumpPath)
+		pgDumpPath = promptString("pg_dump path (leave empty to use PATH)", pgDumpPath)
+		pgGlobals = promptBool("Also back up roles and tablespaces (pg_dumpall --globals-only)?", pgGlobals)
+	} else if mysqldumpPath = promptString("mysqldump path (leave empty to pick mysqldump or mariadb-dump by server)", existing.MysqldumpPath); mysqldumpPath == "" {
+		// Leaving the path unset lets backups choose the tool matching the server
+		if !dumpToolInPath() {
+			if promptBool("No mysqldump found in PATH. Use the built-in dumper?", true) {
+				connDumpMethod = "native"
+			} else {
+				mysqldumpPath = "/opt/homebrew/opt/mysql-client/bin/mysqldump"
+				if !promptBool(fmt.Sprintf("Use mysqldump at '%s'?", mysqldumpPath), true) {
+					mysqldumpPath = promptString("mysqldump path", mysqldumpPath)
+				}
+			}
+		}
+	}
+	if engine == "mariadb" {
+		if existing != nil {
+			mariadbUsers = existing.MariaDBUsers
+		}
+		mariadbUsers = promptBool("Also back up users and grants (mariadb-dump --system=users)?", mariadbUsers)
+	}
+	var connBackupType, xtrabackupPath string
+	var binlogArchive bool
+	if engine != "postgres" {
+		if existing != nil {
+			connBackupType, xtrabackupPath = existing.BackupType, existing.XtrabackupPath
+			binlogArchive = existing.BinlogArchive
+		}
+		connBackupType = strings.ToLower(promptString("Backup type (logical/physical)", firstNonEmpty(connBackupType, domain.BackupTypeLogical)))
+		if connBackupType == domain.BackupTypePhysical {
+			xtrabackupPath = promptString("xtrabackup/mariabackup path (leave empty to pick by server)", xtrabackupPath)
+		} else {
+			connBackupType, xtrabackupPath = "", ""
+			binlogArchive = promptBool("Record binary log positions for point-in-time recovery (needs RELOAD privilege)?", binlogArchive)
 		}
 	}
 
@@ -550,18 +1245,26 @@ func addCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Storage settings
-	storageDriver := promptString("Storage driver (local/s3, leave empty to use .env)", existing.StorageDriver)
+	storageDriver := promptString("Storage driver (local/s3/sftp, leave empty to use .env)", existing.StorageDriver)
 	storageDriver = strings.ToLower(storageDriver)
 	if storageDriver == "" {
 		storageDriver = existing.StorageDriver
 	}
 
 	var path, s3Bucket string
+	var sftpHost, sftpUser, sftpKeyPath string
+	var sftpPort int
 	if storageDriver == "local" {
 		path = promptString("Backup directory path", existing.Path)
 	} else if storageDriver == "s3" {
 		s3Bucket = promptString("S3 bucket name", existing.S3Bucket)
 		path = promptString("S3 path prefix", existing.Path)
+	} else if storageDriver == "sftp" {
+		sftpHost = promptString("SFTP host (leave empty to use .env)", existing.SFTPHost)
+		sftpPort = promptInt("SFTP port", existing.SFTPPort)
+		sftpUser = promptString("SFTP user", existing.SFTPUser)
+		sftpKeyPath = promptString("SFTP key path", existing.SFTPKeyPath)
+		path = promptString("Remote backup directory", existing.Path)
 	}
 
 	// SSH settings
@@ -610,11 +1313,19 @@ func addCmd(cmd *cobra.Command, args []string) error {
 
 	// Create connection
 	newConn := &data.Connection{
+		Engine:         engine,
+		PgDumpPath:     pgDumpPath,
+		PgGlobals:      pgGlobals,
+		MariaDBUsers:   mariadbUsers,
 		Host:           host,
 		Port:           port,
 		User:           user,
 		Password:       password,
 		MysqldumpPath:  mysqldumpPath,
+		DumpMethod:     connDumpMethod,
+		BackupType:     connBackupType,
+		XtrabackupPath: xtrabackupPath,
+		BinlogArchive:  binlogArchive,
 		ExcludedDBs:    excludedDBs,
 		StorageDriver:  storageDriver,
 		Path:           path,
@@ -627,6 +1338,10 @@ func addCmd(cmd *cobra.Command, args []string) error {
 		BastionPort:    bastionPort,
 		BastionUser:    bastionUser,
 		BastionKeyPath: bastionKeyPath,
+		SFTPHost:       sftpHost,
+		SFTPPort:       sftpPort,
+		SFTPUser:       sftpUser,
+		SFTPKeyPath:    sftpKeyPath,
 	}
 
 	if existing != nil {
@@ -644,18 +1359,40 @@ func addCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func getHost(conn *data.Connection) string {
-	if conn == nil {
-		return ""
+// dumpToolInPath reports whether mysqldump or mariadb-dump can be found in PATH
+func dumpToolInPath() bool {
+	for _, tool := range []string{"mysqldump", "mariadb-dump"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return true
+		}
 	}
-	return conn.Host
+	return false
 }
 
-func getPort(conn *data.Connection) int {
+// flavorName returns the display name of a server flavor recorded in a manifest;
+// manifests written before flavors were recorded are from MySQL servers
+func flavorName(flavor string) string {
+	switch flavor {
+	case "mariadb":
+		return "MariaDB"
+	case "postgresql":
+		return "PostgreSQL"
+	}
+	return "MySQL"
+}
+
+func getEngine(conn *data.Connection) string {
+	if conn == nil || conn.Engine == "" {
+		return "mysql"
+	}
+	return conn.Engine
+}
+
+func getHost(conn *data.Connection) string {
 	if conn == nil {
-		return 3306
+		return ""
 	}
-	return conn.Port
+	return conn.Host
 }
 
 func getUser(conn *data.Connection) string {
@@ -732,6 +1469,13 @@ func listCmd(cmd *cobra.Command, args []string) error {
 			}
 			storageInfo += "]"
 		}
+		if s3Info := describeS3Options(conn); s3Info != "" {
+			storageInfo += fmt.Sprintf(" [s3: %s]", s3Info)
+		}
+
+		if conn.Engine != "" && !strings.EqualFold(conn.Engine, "mysql") {
+			storageInfo = fmt.Sprintf(" [engine: %s]", conn.Engine) + storageInfo
+		}
 
 		fmt.Printf("  %s: %s@%s:%d%s\n", connName, conn.User, conn.Host, conn.Port, storageInfo)
 	}
@@ -739,6 +1483,275 @@ func listCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// verifyCmd handles the verify command
+func verifyCmd(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	connManager, err := data.NewConnectionManager("")
+	if err != nil {
+		return fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	conn, err := selectConnection(connManager)
+	if err != nil {
+		return err
+	}
+
+	storageGateway, err := newStorageGateway(cmd, connectionName, conn)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	// Test restores go to a scratch database on the sandbox connection, or on the connection itself
+	var dbGateway *data.DatabaseGateway
+	if restoreTest {
+		target := conn
+		if sandboxName != "" {
+			target, err = connManager.GetConnection(sandboxName)
+			if err != nil {
+				return fmt.Errorf("sandbox connection '%s' not found: %w", sandboxName, err)
+			}
+		}
+		dbGateway, err = newDatabaseGateway(target)
+		if err != nil {
+			return err
+		}
+		defer dbGateway.Close()
+	} else if sandboxName != "" || assertionsDir != "" {
+		return fmt.Errorf("--sandbox and --assertions require --restore-test")
+	}
+
+	useCase := app.NewVerifyUseCase(storageGateway, dbGateway, resolveEncryptionConfig(conn))
+	return useCase.Execute(app.VerifyOptions{
+		Database:      databaseName,
+		All:           verifyAll,
+		RestoreTest:   restoreTest,
+		AssertionsDir: assertionsDir,
+	})
+}
+
+// decryptCmd handles the decrypt command
+func decryptCmd(cmd *cobra.Command, args []string) error {
+	// Works standalone for manual recovery; .env is only read when given explicitly
+	if configPath != "" {
+		if err := godotenv.Load(configPath); err != nil {
+			return fmt.Errorf("failed to load .env file: %w", err)
+		}
+	}
+
+	useCase := app.NewDecryptUseCase(resolveEncryptionConfig(&data.Connection{}))
+	return useCase.Execute(args[0], outputPath, !keepCompressed)
+}
+
+// newBackupsUseCase opens the storage of the selected connection for the backups commands
+func newBackupsUseCase(cmd *cobra.Command) (*app.BackupsUseCase, *data.StorageGateway, error) {
+	if err := loadConfig(); err != nil {
+		return nil, nil, err
+	}
+
+	connManager, err := data.NewConnectionManager("")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	conn, err := selectConnection(connManager)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storageGateway, err := newStorageGateway(cmd, connectionName, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app.NewBackupsUseCase(storageGateway), storageGateway, nil
+}
+
+// parseSince parses --since as a duration back from now (e.g. 7d, 12h) or a date/time
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := domain.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value '%s' (use a duration like 7d or a date like 2006-01-02)", value)
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// backupsListCmd handles the backups list command
+func backupsListCmd(cmd *cobra.Command, args []string) error {
+	sinceTime, err := parseSince(since)
+	if err != nil {
+		return err
+	}
+
+	useCase, storageGateway, err := newBackupsUseCase(cmd)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	backups, err := useCase.List(app.BackupFilter{Database: databaseName, Since: sinceTime})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if backups == nil {
+			backups = []app.BackupDetails{}
+		}
+		return printJSON(backups)
+	}
+
+	if len(backups) == 0 {
+		fmt.Println("No backups found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATABASE\tTYPE\tTIMESTAMP\tSIZE\tCOMPRESSION\tENCRYPTION\tLOCATION\tCHECKSUM")
+	for _, backup := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			backup.Database,
+			backup.Type,
+			backup.Timestamp.Format("2006-01-02 15:04:05"),
+			domain.FormatSize(backup.Size),
+			backup.Compression,
+			firstNonEmpty(backup.Encryption, "none"),
+			backup.Location,
+			firstNonEmpty(backup.Checksum, "-"),
+		)
+	}
+	return w.Flush()
+}
+
+// backupsShowCmd handles the backups show command
+func backupsShowCmd(cmd *cobra.Command, args []string) error {
+	useCase, storageGateway, err := newBackupsUseCase(cmd)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	backup, err := useCase.Show(args[0])
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(backup)
+	}
+
+	fmt.Printf("ID:          %s\n", backup.ID)
+	fmt.Printf("Database:    %s\n", backup.Database)
+	fmt.Printf("Type:        %s\n", backup.Type)
+	fmt.Printf("Timestamp:   %s\n", backup.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("Size:        %s (%d bytes)\n", domain.FormatSize(backup.Size), backup.Size)
+	fmt.Printf("Compression: %s\n", backup.Compression)
+	fmt.Printf("Encryption:  %s\n", firstNonEmpty(backup.Encryption, "none"))
+	fmt.Printf("Location:    %s\n", backup.Location)
+	fmt.Printf("Checksum:    %s\n", firstNonEmpty(backup.Checksum, "-"))
+	if backup.LockedUntil != nil {
+		fmt.Printf("Locked:      until %s\n", backup.LockedUntil.Format("2006-01-02 15:04:05 MST"))
+	}
+	if backup.LegalHold {
+		fmt.Println("Legal hold:  on")
+	}
+	if m := backup.Manifest; m != nil {
+		fmt.Printf("Connection:  %s\n", firstNonEmpty(m.Connection, "-"))
+		fmt.Printf("Source:      %s (%s %s)\n", m.Host, flavorName(m.ServerFlavor), firstNonEmpty(m.ServerVersion, "unknown"))
+		fmt.Printf("Dumper:      %s %s\n", firstNonEmpty(m.Dumper.Version, m.Dumper.Tool), strings.Join(m.Dumper.Flags, " "))
+		if b := m.Binlog; b != nil && b.Position > 0 {
+			fmt.Printf("Binlog:      %s:%d %s\n", b.File, b.Position, b.GTIDSet)
+		} else if b != nil {
+			fmt.Printf("Binlog:      %s\n", b.File)
+		}
+		fmt.Printf("Duration:    %s\n", time.Duration(m.DurationSeconds*float64(time.Second)).Round(time.Millisecond))
+		fmt.Printf("Dump size:   %s uncompressed, %s compressed\n", domain.FormatSize(m.UncompressedSize), domain.FormatSize(m.CompressedSize))
+		if m.Encryption != nil && len(m.Encryption.Recipients) > 0 {
+			fmt.Printf("Recipients:  %s\n", strings.Join(m.Encryption.Recipients, ", "))
+		}
+		fmt.Printf("Tool:        db-backup %s\n", m.ToolVersion)
+	}
+	return nil
+}
+
+// backupsDeleteCmd handles the backups delete command
+func backupsDeleteCmd(cmd *cobra.Command, args []string) error {
+	useCase, storageGateway, err := newBackupsUseCase(cmd)
+	if err != nil {
+		return err
+	}
+	defer storageGateway.Close()
+
+	backup, err := useCase.Show(args[0])
+	if err != nil {
+		return err
+	}
+
+	if !assumeYes && !promptBool(fmt.Sprintf("Are you sure you want to delete backup '%s'?", backup.Location), false) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	return useCase.Delete(backup.ID)
+}
+
+// describeS3Options summarizes the S3 object settings of a connection
+func describeS3Options(conn *data.Connection) string {
+	var parts []string
+	if conn.S3SSE != "" {
+		sse := conn.S3SSE
+		if conn.S3KMSKeyID != "" {
+			sse += " " + conn.S3KMSKeyID
+		}
+		parts = append(parts, "sse: "+sse)
+	}
+	if conn.S3StorageClass != "" {
+		parts = append(parts, "class: "+conn.S3StorageClass)
+	}
+	if conn.S3ChecksumAlgorithm != "" {
+		parts = append(parts, "checksum: "+conn.S3ChecksumAlgorithm)
+	}
+	if conn.S3ObjectLockMode != "" || conn.S3ObjectLockRetention != "" {
+		parts = append(parts, fmt.Sprintf("object lock: %s %s", firstNonEmpty(conn.S3ObjectLockMode, "GOVERNANCE"), conn.S3ObjectLockRetention))
+	}
+	if conn.S3LegalHold {
+		parts = append(parts, "legal hold")
+	}
+	if pairs := formatKeyValues(conn.S3Tags); pairs != "" {
+		parts = append(parts, "tags: "+pairs)
+	}
+	if pairs := formatKeyValues(conn.S3Metadata); pairs != "" {
+		parts = append(parts, "metadata: "+pairs)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatKeyValues renders a map as sorted key=value pairs
+func formatKeyValues(values map[string]string) string {
+	pairs := make([]string, 0, len(values))
+	for key, value := range values {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
 // initCmd handles the init command
 func initCmd(cmd *cobra.Command, args []string) error {
 	if configPath == "" {
@@ -764,26 +1777,163 @@ func cronCmd(cmd *cobra.Command, args []string) error {
 // NewRootCmd creates the root command
 func NewRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
-		Use:   "db-backup",
-		Short: "Database backup tool with multiple connection support",
-		Long:  "A command-line tool for backing up MySQL databases to local storage or AWS S3.",
+		Use:     "db-backup",
+		Short:   "Database backup tool with multiple connection support",
+		Long:    "A command-line tool for backing up MySQL databases to local storage or AWS S3.",
+		Version: fmt.Sprintf("%s (commit %s, built %s)", Version, GitCommit, BuildTime),
 	}
 
 	// Backup command
 	backupCmd := &cobra.Command{
 		Use:   "backup",
-		Short: "Run backup for a database connection",
+		Short: "Run backup for one or more database connections",
 		RunE:  backupCmd,
 	}
 	backupCmd.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
+	backupCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be backed up and pruned without writing or deleting anything")
 	backupCmd.Flags().StringVar(&connectionName, "connection", "", "Name of the connection to use for backup")
+	backupCmd.Flags().BoolVar(&backupAll, "all", false, "Back up every configured connection")
+	backupCmd.Flags().StringSliceVar(&backupNames, "connections", nil, "Comma-separated connections to back up")
+	backupCmd.Flags().IntVar(&parallelConns, "parallel-connections", 1, "Number of connections to back up at once with --all or --connections")
 	backupCmd.Flags().IntVar(&retention, "retention", 0, "Number of backups to retain")
+	backupCmd.Flags().StringVar(&maxAge, "max-age", "", "Prune backups older than this (e.g. 30d, 8w)")
+	backupCmd.Flags().StringVar(&maxTotalSize, "max-total-size", "", "Prune the oldest backups once a database's backups exceed this size (e.g. 500GB)")
+	backupCmd.Flags().IntVar(&minKeep, "min-keep", 0, "Minimum number of most recent backups that are never pruned (default 1)")
+	backupCmd.Flags().StringVar(&storageType, "storage", "", "Storage driver to use (e.g. local, s3, sftp)")
 	backupCmd.Flags().Bool("local", false, "Store backups locally")
 	backupCmd.Flags().Bool("s3", false, "Store backups in S3")
 	backupCmd.Flags().StringVar(&backupDir, "backup-dir", "", "Local directory to store backups in")
 	backupCmd.Flags().StringVar(&mysqldumpPath, "mysqldump", "", "Path to mysqldump binary")
+	backupCmd.Flags().StringVar(&dumpMethod, "dump-method", "", "How MySQL databases are dumped: mysqldump or native (default from connection or DUMP_METHOD)")
+	backupCmd.Flags().StringVar(&backupType, "type", "", "Backup type: logical (SQL dumps) or physical (xtrabackup/mariabackup, MySQL only)")
+	backupCmd.Flags().IntVar(&parallel, "parallel", 0, "Number of databases to back up at once (default 1)")
 	backupCmd.Flags().BoolVar(&compress, "compress", true, "Compress backups with gzip")
 	backupCmd.Flags().BoolVar(&noCompress, "no-compress", false, "Don't compress backups")
+	backupCmd.Flags().StringVar(&compression, "compression", "", "Compression codec: gzip, zstd, xz, lz4 or none")
+	backupCmd.Flags().IntVar(&compressLevel, "compression-level", 0, "Compression level (0 uses the codec default)")
+	backupCmd.Flags().StringVar(&encryption, "encryption", "", "Encrypt backups with age, gpg, aes or none")
+	backupCmd.Flags().StringSliceVar(&recipients, "recipient", nil, "Encryption recipient public key or key file (repeatable)")
+	backupCmd.Flags().StringVar(&keyFile, "key-file", "", "Secret key file for aes encryption (instead of ENCRYPTION_PASSPHRASE)")
+
+	// Restore command
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a stored backup into a database",
+		RunE:  restoreCmd,
+	}
+	restoreCmd.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
+	restoreCmd.Flags().StringVar(&connectionName, "connection", "", "Name of the connection to restore into")
+	restoreCmd.Flags().StringVar(&databaseName, "database", "", "Database whose backup should be restored")
+	restoreCmd.Flags().StringVar(&backupName, "backup", "", "Backup file name to restore")
+	restoreCmd.Flags().BoolVar(&latestBackup, "latest", false, "Restore the most recent backup")
+	restoreCmd.Flags().StringVar(&targetDatabase, "target-database", "", "Restore into a different database name")
+	restoreCmd.Flags().BoolVar(&forceRestore, "force", false, "Overwrite a non-empty target database")
+	restoreCmd.Flags().StringVar(&targetDir, "target-dir", "", "Directory on the database host to extract and prepare a physical backup in")
+	restoreCmd.Flags().StringVar(&restoreTo, "to", "", "Restore the database as it was at this local time (e.g. \"2026-10-01 12:34:00\") from a backup and archived binary logs")
+	restoreCmd.Flags().BoolVar(&copyBack, "copy-back", false, "Copy a prepared physical backup into the data directory of the stopped server")
+	restoreCmd.Flags().StringVar(&storageType, "storage", "", "Storage driver to read backups from (e.g. local, s3, sftp)")
+	restoreCmd.Flags().Bool("local", false, "Read backups from local storage")
+	restoreCmd.Flags().Bool("s3", false, "Read backups from S3")
+	restoreCmd.Flags().StringVar(&backupDir, "backup-dir", "", "Local directory backups are stored in")
+	restoreCmd.Flags().StringVar(&mysqlPath, "mysql", "", "Path to mysql client binary")
+	restoreCmd.Flags().StringVar(&identityFile, "identity", "", "Private key file used to decrypt encrypted backups")
+	restoreCmd.Flags().StringVar(&keyFile, "key-file", "", "Secret key file used to decrypt aes encrypted backups")
+
+	// Binlog command
+	binlogCmd := &cobra.Command{
+		Use:   "binlog",
+		Short: "Archive the binary logs of a MySQL or MariaDB server for point-in-time recovery",
+		RunE:  binlogCmd,
+	}
+	binlogCmd.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
+	binlogCmd.Flags().StringVar(&connectionName, "connection", "", "Name of the connection whose binary logs to archive")
+	binlogCmd.Flags().BoolVar(&flushBinlogs, "flush", false, "Close the current binary log first so that it is archived too")
+	binlogCmd.Flags().DurationVar(&binlogInterval, "interval", 0, "Keep archiving at this interval (e.g. 5m) until stopped")
+	binlogCmd.Flags().StringVar(&storageType, "storage", "", "Storage driver to use (e.g. local, s3, sftp)")
+	binlogCmd.Flags().Bool("local", false, "Store binary logs locally")
+	binlogCmd.Flags().Bool("s3", false, "Store binary logs in S3")
+	binlogCmd.Flags().StringVar(&backupDir, "backup-dir", "", "Local directory to store binary logs in")
+	binlogCmd.Flags().StringVar(&compression, "compression", "", "Compression codec: gzip, zstd, xz, lz4 or none")
+	binlogCmd.Flags().IntVar(&compressLevel, "compression-level", 0, "Compression level (0 uses the codec default)")
+	binlogCmd.Flags().StringVar(&encryption, "encryption", "", "Encrypt binary logs with age, gpg, aes or none")
+	binlogCmd.Flags().StringSliceVar(&recipients, "recipient", nil, "Encryption recipient public key or key file (repeatable)")
+	binlogCmd.Flags().StringVar(&keyFile, "key-file", "", "Secret key file for aes encryption (instead of ENCRYPTION_PASSPHRASE)")
+
+	// Verify command
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check stored backups for corruption and truncation",
+		Long: "Re-reads stored backups, validates their size and SHA-256 against the manifest and decompresses\n" +
+			"the whole stream. Exits with an error if any backup fails.",
+		Args: cobra.NoArgs,
+		RunE: verifyCmd,
+	}
+	verifyCmd.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
+	verifyCmd.Flags().StringVar(&connectionName, "connection", "", "Name of the connection whose backups to verify")
+	verifyCmd.Flags().StringVar(&databaseName, "database", "", "Only verify backups of this database")
+	verifyCmd.Flags().BoolVar(&verifyAll, "all", false, "Verify every stored backup instead of the latest per database")
+	verifyCmd.Flags().BoolVar(&restoreTest, "restore-test", false, "Restore each backup into a scratch database, check its tables and row counts, then drop it")
+	verifyCmd.Flags().StringVar(&sandboxName, "sandbox", "", "Connection to make test restores on (default: the backup's connection)")
+	verifyCmd.Flags().StringVar(&assertionsDir, "assertions", "", "Directory with <database>.sql files of queries that must return true after a test restore")
+	verifyCmd.Flags().StringVar(&mysqlPath, "mysql", "", "Path to mysql client binary used for test restores")
+	verifyCmd.Flags().StringVar(&storageType, "storage", "", "Storage driver to read backups from (e.g. local, s3, sftp)")
+	verifyCmd.Flags().Bool("local", false, "Read backups from local storage")
+	verifyCmd.Flags().Bool("s3", false, "Read backups from S3")
+	verifyCmd.Flags().StringVar(&backupDir, "backup-dir", "", "Local directory backups are stored in")
+	verifyCmd.Flags().StringVar(&identityFile, "identity", "", "Private key used to decrypt encrypted backups")
+	verifyCmd.Flags().StringVar(&keyFile, "key-file", "", "Secret key file used to decrypt aes encrypted backups")
+
+	// Decrypt command
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt FILE",
+		Short: "Decrypt a downloaded backup file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  decryptCmd,
+	}
+	decryptCmd.Flags().StringVar(&configPath, "config", "", "Path to an .env file with encryption settings")
+	decryptCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output file, or - for stdout (default: input without encryption and compression extensions)")
+	decryptCmd.Flags().BoolVar(&keepCompressed, "keep-compressed", false, "Only decrypt, don't decompress")
+	decryptCmd.Flags().StringVar(&identityFile, "identity", "", "Private key file for age or gpg backups")
+	decryptCmd.Flags().StringVar(&keyFile, "key-file", "", "Secret key file for aes backups (instead of ENCRYPTION_PASSPHRASE)")
+
+	// Backups command
+	backupsCmd := &cobra.Command{
+		Use:   "backups",
+		Short: "List, inspect and delete stored backups",
+	}
+	backupsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List stored backups",
+		Args:  cobra.NoArgs,
+		RunE:  backupsListCmd,
+	}
+	backupsListCmd.Flags().StringVar(&databaseName, "database", "", "Only list backups of this database")
+	backupsListCmd.Flags().StringVar(&since, "since", "", "Only list backups newer than a duration (e.g. 7d) or date (e.g. 2006-01-02)")
+	backupsShowCmd := &cobra.Command{
+		Use:   "show ID",
+		Short: "Show the details of a stored backup",
+		Args:  cobra.ExactArgs(1),
+		RunE:  backupsShowCmd,
+	}
+	backupsDeleteCmd := &cobra.Command{
+		Use:   "delete ID",
+		Short: "Delete a stored backup",
+		Args:  cobra.ExactArgs(1),
+		RunE:  backupsDeleteCmd,
+	}
+	backupsDeleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask for confirmation")
+	for _, sub := range []*cobra.Command{backupsListCmd, backupsShowCmd, backupsDeleteCmd} {
+		sub.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
+		sub.Flags().StringVar(&connectionName, "connection", "", "Name of the connection whose storage to use")
+		sub.Flags().StringVar(&storageType, "storage", "", "Storage driver to read backups from (e.g. local, s3, sftp)")
+		sub.Flags().Bool("local", false, "Use local storage")
+		sub.Flags().Bool("s3", false, "Use S3 storage")
+		sub.Flags().StringVar(&backupDir, "backup-dir", "", "Local directory backups are stored in")
+	}
+	for _, sub := range []*cobra.Command{backupsListCmd, backupsShowCmd} {
+		sub.Flags().BoolVar(&jsonOutput, "json", false, "Print JSON instead of a table")
+	}
+	backupsCmd.AddCommand(backupsListCmd, backupsShowCmd, backupsDeleteCmd)
 
 	// Add command
 	addCmd := &cobra.Command{
@@ -824,7 +1974,7 @@ func NewRootCmd() *cobra.Command {
 	}
 	cronCmd.Flags().StringVar(&configPath, "config", "", "Path to the .env file")
 
-	rootCmd.AddCommand(backupCmd, addCmd, removeCmd, listCmd, initCmd, cronCmd)
+	rootCmd.AddCommand(backupCmd, restoreCmd, binlogCmd, verifyCmd, decryptCmd, backupsCmd, addCmd, removeCmd, listCmd, initCmd, cronCmd)
 
 	return rootCmd
 }
@@ -5,10 +5,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Set at build time via -ldflags (see Makefile)
+var (
+	Version   = "dev"
+	BuildTime = "unknown"
+	GitCommit = "unknown"
+)
+
 func main() {
+	cli.Version, cli.GitCommit, cli.BuildTime = Version, GitCommit, BuildTime
 	rootCmd := cli.NewRootCmd()
 	if err := rootCmd.Execute(); err != nil {
 		cobra.CheckErr(err)
 	}
 }
-